@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dream11/livelogs/pkg/configcache"
+	"github.com/dream11/livelogs/util"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	configCacheCmd.AddCommand(configCacheClearCmd)
+	configCmd.AddCommand(configCacheCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage livelogs configuration",
+}
+
+var configCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of log search configs",
+}
+
+var configCacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached log search config",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		livelogsDir, err := util.GetLivelogsDir()
+		if err != nil {
+			return fmt.Errorf("unable to access livelogs directory: %w", err)
+		}
+		if err := configcache.Clear(livelogsDir); err != nil {
+			return fmt.Errorf("error in clearing log search config cache: %w", err)
+		}
+		log.Success("Cleared log search config cache")
+		return nil
+	},
+}
@@ -20,6 +20,7 @@ func SetSetupScript(fs embed.FS) {
 
 func init() {
 	configureCmd.Flags().BoolP(constants.ArgumentVerbose, "v", false, "verbose logging")
+	configureCmd.Flags().DurationP(constants.ArgumentTimeout, "", constants.GlobalLogsCommandTimeout, "Maximum duration of the configuration, 0 for unlimited")
 	rootCmd.AddCommand(configureCmd)
 }
 
@@ -27,63 +28,48 @@ var configureCmd = &cobra.Command{
 	Use:   "configure",
 	Short: "To run livelogs configuration script",
 	Long:  "To run livelogs configuration script",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctx, cancel := context.WithTimeout(context.Background(), constants.GlobalLogsCommandTimeout)
-		defer cancel()
-		select {
-		case <-configureCmdHandler(ctx, cmd):
-			log.Debug("Operation completed successfully.")
-		case <-ctx.Done():
-			if ctx.Err() == context.DeadlineExceeded {
-				log.ErrorAndExit(fmt.Sprintf("Operation timed out after %v minutes", constants.GlobalLogsCommandTimeout.Minutes()))
-			} else {
-				log.Error(fmt.Sprintf("Something went wrong. Error: %v", ctx.Err()))
-			}
-		}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWithSessionTimeout(cmd, false, func(context.Context, *sessionTimer) error {
+			return configureCmdHandler(cmd)
+		})
 	},
 }
 
-func configureCmdHandler(ctx context.Context, cmd *cobra.Command) <-chan string {
-	result := make(chan string)
+func configureCmdHandler(cmd *cobra.Command) error {
 	isVerboseLoggingEnabled, _ := cmd.Flags().GetBool(constants.ArgumentVerbose)
 	if isVerboseLoggingEnabled {
 		log.EnableDebugMode()
 	}
 
-	go func() {
-		defer close(result)
-		tempDir := os.TempDir()
-		scriptPath := filepath.Join(tempDir, "livelogs_setup.sh")
-		err := os.WriteFile(scriptPath, setupScriptContent(), 0755)
-		if err != nil {
-			log.ErrorAndExit(fmt.Sprintf("Failed to write script to temporary file: %v", err))
-		}
-
-		// Ensure the script is executable
-		err = os.Chmod(scriptPath, 0755)
-		if err != nil {
-			log.ErrorAndExit(fmt.Sprintf("Failed to set executable permissions on script: %v", err))
-		}
-		var exitCode = shell.Exec(fmt.Sprintf("sh %s", scriptPath))
-		if exitCode != 0 {
-			log.ErrorAndExit("Configuration script execution failed")
-		}
+	content, err := setupScriptContent()
+	if err != nil {
+		return err
+	}
+	tempDir := os.TempDir()
+	scriptPath := filepath.Join(tempDir, "livelogs_setup.sh")
+	err = os.WriteFile(scriptPath, content, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to write script to temporary file: %w", err)
+	}
 
-		log.Info("✅ Configuration script executed successfully.")
+	// Ensure the script is executable
+	err = os.Chmod(scriptPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to set executable permissions on script: %w", err)
+	}
+	var exitCode = shell.Exec(fmt.Sprintf("sh %s", scriptPath))
+	if exitCode != 0 {
+		return fmt.Errorf("configuration script execution failed with exit code %d", exitCode)
+	}
 
-		select {
-		case <-ctx.Done():
-			return
-		case result <- "Command executed successfully.":
-		}
-	}()
-	return result
+	log.Info("✅ Configuration script executed successfully.")
+	return nil
 }
 
-func setupScriptContent() []byte {
+func setupScriptContent() ([]byte, error) {
 	content, err := setupScript.ReadFile(constants.LivelogsSetupScriptPath)
 	if err != nil {
-		log.ErrorAndExit(fmt.Sprintf("Failed to read embedded script: %v", err))
+		return nil, fmt.Errorf("failed to read embedded script: %w", err)
 	}
-	return content
+	return content, nil
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/pkg/hostkeys"
+	"github.com/dream11/livelogs/pkg/livelogs"
+	"github.com/dream11/livelogs/util"
+)
+
+var (
+	// errInvalidArguments : flags or arguments that can not be used as they are
+	errInvalidArguments = errors.New("invalid arguments")
+	// errTimeout : the session expired before the command completed
+	errTimeout = errors.New("operation timed out")
+	// errInterrupted : the user stopped the command, nothing is printed for it
+	errInterrupted = errors.New("interrupted")
+)
+
+func invalidArguments(err error) error {
+	return fmt.Errorf("%w: %w", errInvalidArguments, err)
+}
+
+// exitCode : exit status of a command that failed with the error, documented in the troubleshooting section of the README
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errInterrupted):
+		return constants.ExitCodeInterrupted
+	case errors.Is(err, errTimeout):
+		return constants.ExitCodeTimeout
+	case errors.Is(err, errInvalidArguments), errors.Is(err, livelogs.ErrInvalidQuery), errors.Is(err, livelogs.ErrOutOfRetention):
+		return constants.ExitCodeUsage
+	case errors.Is(err, livelogs.ErrAuth), errors.Is(err, livelogs.ErrHostKey):
+		return constants.ExitCodeAuth
+	case errors.Is(err, livelogs.ErrConfig), errors.Is(err, livelogs.ErrNotOnboarded):
+		return constants.ExitCodeConfig
+	case errors.Is(err, livelogs.ErrDns):
+		return constants.ExitCodeDns
+	case errors.Is(err, livelogs.ErrKafka):
+		return constants.ExitCodeKafka
+	case errors.Is(err, livelogs.ErrAgent):
+		return constants.ExitCodeAgent
+	}
+	return constants.ExitCodeError
+}
+
+// errorMessage : message of the error, a changed host key is spelled out as it may be an attack
+func errorMessage(err error) string {
+	var changedKeyError *hostkeys.ChangedKeyError
+	if errors.As(err, &changedKeyError) {
+		return fmt.Sprintf("WARNING: the host key of central livelogs agent %s has changed!\n"+
+			"Presented: %s\nExpected: %s (from %s)",
+			changedKeyError.Host, changedKeyError.Fingerprint, strings.Join(changedKeyError.Expected, ", "), changedKeyError.Source)
+	}
+	message := err.Error()
+	if len(message) == 0 {
+		return "Unknown error"
+	}
+	return strings.ToUpper(message[:1]) + message[1:]
+}
+
+// remediationHint : what the user can do about the error, empty when there is nothing to suggest
+func remediationHint(err error) string {
+	var changedKeyError *hostkeys.ChangedKeyError
+	switch {
+	case errors.As(err, &changedKeyError):
+		return "Someone may be impersonating the agent. If the key was rotated on purpose, remove the old entry and connect again."
+	case errors.Is(err, errTimeout):
+		return "Use --timeout to run longer sessions (0 for unlimited)"
+	case errors.Is(err, util.ErrNotAuthorized):
+		return "Run `livelogs login` and try again"
+	case errors.Is(err, util.ErrOrchestratorUnavailable):
+		return "Connect to the VPN and try again, or point livelogs at another orchestrator with --orchestrator_url"
+	case errors.Is(err, livelogs.ErrAuth):
+		return "The credentials of the central livelogs agent in the log search config are invalid, report it to the livelogs maintainers"
+	case errors.Is(err, livelogs.ErrNotOnboarded):
+		return "Check the env, service_name and component_name, or onboard the component on Log Central"
+	case errors.Is(err, livelogs.ErrDns):
+		return "Connect to the correct VPN, with split-horizon DNS pass its resolver with --dns_server"
+	case errors.Is(err, livelogs.ErrKafka), errors.Is(err, livelogs.ErrAgent):
+		return "Connect to the correct VPN and try again, --verbose shows every attempt"
+	case errors.Is(err, errInvalidArguments), errors.Is(err, livelogs.ErrInvalidQuery):
+		return "Run the command with --help to see its flags"
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/util"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store the token used to authenticate to the livelogs orchestrator",
+	Long:  "Store the token used to authenticate to the livelogs orchestrator, in ~/.livelogs/credentials.json. It is prompted for, or read from stdin. LIVELOGS_TOKEN takes precedence over it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		orchestratorUrl, _ := cmd.Flags().GetString(constants.ArgumentOrchestratorUrl)
+		caBundle, _ := cmd.Flags().GetString(constants.ArgumentCABundle)
+		orchestrator, err := util.NewOrchestrator(orchestratorUrl, caBundle, log.Warn)
+		if err != nil {
+			return fmt.Errorf("error in configuring livelogs orchestrator: %w", err)
+		}
+
+		// The token is never taken from the command line, where it would end up in the shell history and ps
+		token, err := readToken(orchestrator.Url())
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(token)
+		if token == "" {
+			return invalidArguments(errors.New("token can not be empty"))
+		}
+
+		path, err := util.StoreToken(orchestrator.Url(), token)
+		if err != nil {
+			return fmt.Errorf("error in storing credentials: %w", err)
+		}
+		log.Success("Saved credentials for " + orchestrator.Url() + " in " + path)
+		return nil
+	},
+}
+
+// readToken : prompt for the token on a terminal, read it from stdin otherwise
+func readToken(orchestratorUrl string) (string, error) {
+	if isTerminal(os.Stdin) {
+		token, err := log.AskSecret("Token for " + orchestratorUrl + ":")
+		if err != nil {
+			return "", fmt.Errorf("error in reading token: %w", err)
+		}
+		return token, nil
+	}
+	token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && token == "" {
+		return "", fmt.Errorf("error in reading token from stdin: %w", err)
+	}
+	return token, nil
+}
@@ -9,25 +9,21 @@ import (
 	"os"
 	"reflect"
 	"strings"
-	"sync"
-	"time"
 
-	"github.com/Shopify/sarama"
 	"github.com/dream11/livelogs/constants"
 	"github.com/dream11/livelogs/models"
-	"github.com/dream11/livelogs/pkg/encryption"
+	"github.com/dream11/livelogs/pkg/formatter"
+	"github.com/dream11/livelogs/pkg/livelogs"
 	"github.com/dream11/livelogs/pkg/logger"
-	"github.com/dream11/livelogs/protobuf"
 	"github.com/dream11/livelogs/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"golang.org/x/crypto/ssh"
-	"google.golang.org/protobuf/proto"
 )
 
 var log logger.Logger
 
 func init() {
+	addRecordOutputFlags(logsCmd.Flags())
 	logsCmd.Flags().StringP(constants.ArgumentEnv, "e", "", "* environment name (Mandatory)")
 	logsCmd.Flags().StringP(constants.ArgumentServiceName, "s", "", "service_name")
 	logsCmd.Flags().StringP(constants.ArgumentComponentName, "c", "", "component_name")
@@ -36,89 +32,253 @@ func init() {
 	logsCmd.Flags().StringP(constants.ArgumentOrg, "o", "d11", "org name can be: [d11, dp, hulk]")
 	logsCmd.Flags().StringP(constants.ArgumentCloudProvider, "", "aws", "cloud_provider can be: [aws, gcp] (Default is aws)")
 	logsCmd.Flags().StringP(constants.ArgumentAccount, "a", "", "account type [prod, load, stag] (Default is based on env name if env is prod or uat then account is prod)")
-	logsCmd.Flags().StringP(constants.ArgumentStartTime, "", "", "Start time if you want to see historic logs (Give the time in IST, with this format \"2025-01-02 15:04:05\")")
-	logsCmd.Flags().StringP(constants.ArgumentEndTime, "", "", "End time if you want to see historic logs and wanted to see limited logs upto this time (Give the time in IST, with this format \"2006-01-02 15:04:05\")")
+	logsCmd.Flags().StringP(constants.ArgumentStartTime, "", "", "Start time if you want to see historic logs, in --timezone (Formats: \"2025-01-02 15:04:05\", \"2025-01-02\", RFC3339, \"today 14:00\", \"yesterday 14:00\", -2h)")
+	logsCmd.Flags().StringP(constants.ArgumentEndTime, "", "", "End time if you want to see historic logs and wanted to see limited logs upto this time, in --timezone (Same formats as --start_time)")
+	logsCmd.Flags().BoolP(constants.ArgumentNoFollow, "", false, "Exit once the logs that exist when the command starts are read instead of following new logs (Implied by --end_time)")
+	logsCmd.Flags().StringP(constants.ArgumentResume, "", "", "Save the position of this tail under a name and continue from it on the next run (Name is \"default\" when passed without a value)")
+	logsCmd.Flags().Lookup(constants.ArgumentResume).NoOptDefVal = constants.DefaultResumeName
+	logsCmd.Flags().StringP(constants.ResumeOffsets, "", "", "Offsets to resume partitions from")
+	logsCmd.Flags().BoolP(constants.EmitOffsets, "", false, "Stream offset checkpoints")
+	logsCmd.Flags().BoolP(constants.ArgumentCompress, "", false, "Compress the logs sent by the central livelogs agent (Default is on for historical queries using --since or --start_time)")
+	logsCmd.Flags().StringP(constants.Compression, "", "", "Comma-separated compression codecs understood by the local livelogs client, in order of preference")
+	logsCmd.Flags().IntP(constants.Protocol, "", 0, "Newest framed protocol version understood by the local livelogs client, 0 for text output")
+	logsCmd.Flags().StringP(constants.ArgumentTimezone, "", "", "IANA timezone of --start_time and --end_time, e.g. UTC, Europe/London or Local for the machine timezone (Default is Asia/Kolkata)")
 	logsCmd.Flags().StringP(constants.ArgumentSince, "", "", "When you want to see last 10 minute logs or last 1 hour logs just pass here as 10m or 1h")
 	logsCmd.Flags().StringP(constants.ArgumentLinuxOperation, "l", "", "Linux operation you want to perform on streaming logs example  --linux_operation 'grep \"error\" | grep -iv \"user\"'")
 	logsCmd.Flags().BoolP(constants.ArgumentVerbose, "v", false, "verbose logging")
-	logsCmd.Flags().StringP(constants.LogSearchConfig, "", "", "Log search config")
-	logsCmd.Flags().StringP(constants.ArgumentShowTags, "", "", "Comma-separated list of ddtags to display. If not specified, all ddtags will be shown by default.")
+	logsCmd.Flags().StringP(constants.ArgumentProfile, "p", "", "Profile of ~/.livelogs/config.yaml providing defaults for env, org, account, cloud_provider, service_name, component_name, show_tags, output, timezone and trust_host_keys (Default is "+constants.EnvLivelogsProfile+")")
+	logsCmd.Flags().StringP(constants.ArgumentRecord, "", "", "Save the raw Kafka records read by this session to a file, to be replayed with: livelogs replay <file> (Needs direct access to Kafka, it is not available in local mode where logs are read through the central livelogs agent)")
+	logsCmd.Flags().StringP(constants.ArgumentSource, "", "", "Where logs are read from: ["+constants.SourceKafka+", "+constants.SourceAgent+", "+constants.SourceStdin+", "+constants.SourceFilePrefix+"<path>] (Default is "+constants.SourceKafka+" in central mode and "+constants.SourceAgent+" in local mode, stdin and files hold records as printed by --output ndjson or plain text lines)")
+	logsCmd.Flags().StringP(constants.ArgumentMode, "", "", "Run mode can be: ["+constants.ModeLocal+", "+constants.ModeCentral+", "+constants.ModeAuto+"] (Default is "+constants.EnvLivelogsMode+", else auto which detects cloud machines and caches the result)")
+	logsCmd.Flags().StringP(constants.ArgumentDnsServer, "", "", "DNS server used to resolve livelogs hosts as host[:port], e.g. the VPN resolver for split-horizon DNS (Default is the system resolver, or "+constants.EnvDnsServer+")")
+	logsCmd.Flags().BoolP(constants.ArgumentTrustHostKeys, "", false, "Trust and record the host key of a central livelogs agent seen for the first time, as ssh does, when it is neither pinned by the orchestrator nor in ~/.ssh/known_hosts")
+	logsCmd.Flags().DurationP(constants.ArgumentTimeout, "", constants.GlobalLogsCommandTimeout, "Session duration, 0 for unlimited (Always capped by the maximum allowed by the server)")
+	logsCmd.Flags().StringP(constants.LogSearchConfig, "", "", "Log search config, - to read it from stdin (deprecated inline JSON is still accepted)")
+	logsCmd.Flags().StringP(constants.EncodedFilter, "", "", "Encoded filter expression")
 
 	// To enable debug mode
 	_ = logsCmd.Flags().MarkHidden(constants.ArgumentVerbose)
 	// Used only for central livelogs agent
 	_ = logsCmd.Flags().MarkHidden(constants.LogSearchConfig)
+	_ = logsCmd.Flags().MarkHidden(constants.EncodedFilter)
+	_ = logsCmd.Flags().MarkHidden(constants.ResumeOffsets)
+	_ = logsCmd.Flags().MarkHidden(constants.EmitOffsets)
+	_ = logsCmd.Flags().MarkHidden(constants.Protocol)
+	_ = logsCmd.Flags().MarkHidden(constants.Compression)
+	// Runs arbitrary shell on the central livelogs agent, kept for backward compatibility
+	_ = logsCmd.Flags().MarkDeprecated(constants.ArgumentLinuxOperation, "use --filter instead")
+
+	// --no-follow is the name the flag was first released with
+	logsCmd.Flags().SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == constants.ArgumentNoFollowAlias {
+			name = constants.ArgumentNoFollow
+		}
+		return pflag.NormalizedName(name)
+	})
 
 	logsCmd.MarkFlagsRequiredTogether(constants.ArgumentEnv)
 	rootCmd.AddCommand(logsCmd)
 }
 
+// addRecordOutputFlags : flags selecting, ordering and printing records, shared by the commands that print records
+func addRecordOutputFlags(flags *pflag.FlagSet) {
+	flags.BoolP(constants.ArgumentOrdered, "", false, "Print records of all partitions in timestamp order (Strict order for bounded queries, within --reorder_window when following new logs)")
+	flags.DurationP(constants.ArgumentReorderWindow, "", constants.DefaultReorderWindow, "How long --ordered holds new logs to put late records back in order")
+	flags.StringP(constants.ArgumentShowTags, "", "", "Comma-separated list of ddtags to display. If not specified, all ddtags will be shown by default.")
+	flags.StringP(constants.ArgumentOutput, "", formatter.OutputText, "Output format can be: ["+strings.Join(formatter.SupportedOutputs, ", ")+"]")
+	flags.StringArray(constants.ArgumentSink, nil, "Where records are written, repeat it to write to several sinks: ["+constants.SinkTerminal+", file:<path>[?max_size=100MB&max_files=5&format=ndjson], unix:<path>, tcp:<host:port>, http(s)://<url>] (Default is "+constants.SinkTerminal+", other sinks write ndjson)")
+	flags.StringP(constants.ArgumentTemplate, "", "", "Go text/template used to render each record, example --template '{{.Service}} {{.Message}}' (Overrides --output)")
+	flags.StringP(constants.ArgumentTimestamps, "", "", "Prefix each record with its event time, format can be: ["+strings.Join(formatter.SupportedTimestamps, ", ")+"] (Default is rfc3339 when passed without a value)")
+	flags.Lookup(constants.ArgumentTimestamps).NoOptDefVal = formatter.TimestampRFC3339
+	flags.StringP(constants.ArgumentLevel, "", "", "Show only records of this level and above (e.g. warn), or exactly the levels in a comma-separated list (e.g. warn,fatal). Levels: trace, debug, info, warn, error, fatal")
+	flags.StringP(constants.ArgumentFilter, "f", "", "Filter expression evaluated on every record, example --filter 'level>=warn and ddtags.region == \"ap-south-1\" and message ~ /timeout/i'")
+	flags.StringP(constants.ArgumentLevelMapping, "", "", "Comma-separated producer status to level mapping used before the built-in ones, example --level_mapping 'sev1=fatal,notice=warn'")
+}
+
 var logsCmd = &cobra.Command{
 	Use:   "logs",
 	Short: "To print your component logs",
 	Long:  "To print your component logs",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctx, cancel := context.WithTimeout(context.Background(), constants.GlobalLogsCommandTimeout)
-		defer cancel()
-		select {
-		case <-logsCmdHandler(ctx, cmd, args):
-			log.Debug("Operation completed successfully.")
-		case <-ctx.Done():
-			if ctx.Err() == context.DeadlineExceeded {
-				log.ErrorAndExit(fmt.Sprintf("Operation timed out after %v minutes", constants.GlobalLogsCommandTimeout.Minutes()))
-			} else {
-				log.Error(fmt.Sprintf("Something went wrong. Error: %v", ctx.Err()))
-			}
-		}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWithSessionTimeout(cmd, true, func(ctx context.Context, timer *sessionTimer) error {
+			return logsCmdHandler(ctx, cmd, timer)
+		})
 	},
 }
 
-func logsCmdHandler(ctx context.Context, cmd *cobra.Command, args []string) <-chan string {
-	result := make(chan string)
+func logsCmdHandler(ctx context.Context, cmd *cobra.Command, timer *sessionTimer) error {
+	isVerboseLoggingEnabled, _ := cmd.Flags().GetBool(constants.ArgumentVerbose)
+	if isVerboseLoggingEnabled {
+		log.EnableDebugMode()
+	}
 
-	go func() {
-		isVerboseLoggingEnabled, _ := cmd.Flags().GetBool(constants.ArgumentVerbose)
-		if isVerboseLoggingEnabled {
-			log.EnableDebugMode()
-		}
+	dnsServer, _ := cmd.Flags().GetString(constants.ArgumentDnsServer)
+	if dnsServer == "" {
+		dnsServer = os.Getenv(constants.EnvDnsServer)
+	}
+	mode, _ := cmd.Flags().GetString(constants.ArgumentMode)
+	client, err := newClient(cmd, livelogs.Options{Mode: mode, DnsServer: dnsServer})
+	if err != nil {
+		return err
+	}
+
+	logCmdArgs, err := parseArguments(cmd)
+	if err != nil {
+		return err
+	}
+	log.Debug(fmt.Sprintf("Command arguments: %+v", logCmdArgs))
+
+	printer, err := newRecordPrinter(&logCmdArgs)
+	if err != nil {
+		return invalidArguments(err)
+	}
+	defer printer.close()
 
-		logCmdArgs := parseArguments(cmd)
-		log.Debug(fmt.Sprintf("Command arguments: %+v", logCmdArgs))
+	sourceFlag, _ := cmd.Flags().GetString(constants.ArgumentSource)
+	source, err := livelogs.ParseSource(sourceFlag)
+	if err != nil {
+		return err
+	}
+	if source, err = client.ResolveSource(source); err != nil {
+		return err
+	}
+	query := newQuery(&logCmdArgs, source)
 
-		if util.IsCloudMachine() {
-			log.Debug("Identified as central live log agent host")
+	flushOutput := func() {}
+	var checkpointer *resumeCheckpointer
+	if !source.IsCapture() {
+		if source.Kind == constants.SourceKafka {
+			if logCmdArgs.Protocol > 0 {
+				if flushOutput, err = startFramedOutput(ctx, printer, logCmdArgs.Protocol, logCmdArgs.Compression); err != nil {
+					return err
+				}
+			}
 			if logCmdArgs.ComponentType == "application" {
 				log.Success(fmt.Sprintf("Reading logs for service_name: %s component_name: %s env: %s org: %s account: %s cloudProvider: %s", logCmdArgs.ServiceName, logCmdArgs.ComponentName, logCmdArgs.Env, logCmdArgs.Org, logCmdArgs.Account, logCmdArgs.CloudProvider))
 			} else if logCmdArgs.ComponentType == "asg" {
 				log.Success(fmt.Sprintf("Reading ASG logs for asg_name: %s env: %s org: %s account: %s", logCmdArgs.AsgName, logCmdArgs.Env, logCmdArgs.Org, logCmdArgs.Account))
 			}
-			var logSearchConfig models.LogSearchConfig
-			if logCmdArgs.LogSearchConfig == (models.LogSearchConfig{}) {
-				log.Debug("Log search config is empty so fetching...")
-				logSearchConfig = util.GetLogsSearchConfig(logCmdArgs.Env, logCmdArgs.Org, logCmdArgs.Account, logCmdArgs.CloudProvider, logCmdArgs.ServiceName, logCmdArgs.ComponentName, logCmdArgs.ComponentType, logCmdArgs.AsgName)
-				validateArguments(&logCmdArgs, &logSearchConfig)
-			} else {
-				log.Debug("Log search config is not empty so using it...")
-				logSearchConfig = logCmdArgs.LogSearchConfig
+		}
+
+		if reflect.DeepEqual(logCmdArgs.LogSearchConfig, models.LogSearchConfig{}) {
+			log.Debug("Log search config is empty so fetching...")
+			logSearchConfig, err := client.ResolveConfig(query)
+			if err != nil {
+				return err
 			}
-			readFromKafka(&logCmdArgs, &logSearchConfig)
+			query.Config = &logSearchConfig
 		} else {
-			log.Debug("Identified as local live log agent host")
-			logSearchConfig := util.GetLogsSearchConfig(logCmdArgs.Env, logCmdArgs.Org, logCmdArgs.Account, logCmdArgs.CloudProvider, logCmdArgs.ServiceName, logCmdArgs.ComponentName, logCmdArgs.ComponentType, logCmdArgs.AsgName)
-			validateArguments(&logCmdArgs, &logSearchConfig)
-			commandForCentralLivelogsAgent := getCommandForCentralLivelogsAgent(cmd, args, logCmdArgs.LinuxOperation, &logSearchConfig)
-			readFromCentralLivelogsAgent(commandForCentralLivelogsAgent, logSearchConfig, &logCmdArgs)
+			log.Debug("Log search config is not empty so using it...")
+			query.Config = &logCmdArgs.LogSearchConfig
 		}
-		select {
-		case <-ctx.Done():
-			return
-		case result <- "Command executed successfully.":
+		timer.applyServerLimit(query.Config.MaxSessionMinutes)
+
+		if logCmdArgs.Resume != "" {
+			if checkpointer, err = getResumeCheckpointer(logCmdArgs.Resume, query.Config.Topic); err != nil {
+				return err
+			}
+			query.ResumeOffsets = checkpointer.nextOffsets()
 		}
-	}()
-	return result
+	}
+
+	session, err := client.Open(ctx, query)
+	if err != nil {
+		return err
+	}
+	delivered := newDeliveredOffsets()
+	offsets := delivered.snapshot
+	if checkpointer != nil {
+		go checkpointer.run(ctx, offsets)
+	}
+	if logCmdArgs.EmitOffsets {
+		go emitOffsetCheckpoints(ctx, printer, offsets)
+	}
+
+	for record := range session.Records {
+		printer.printRecord(record)
+		delivered.add(record)
+	}
+	if err := session.Err(); err != nil {
+		return err
+	}
+
+	if logCmdArgs.Record != "" {
+		log.Status(fmt.Sprintf("Recorded %d Kafka messages to %s, replay them with: livelogs replay %s", session.Recorded(), logCmdArgs.Record, logCmdArgs.Record))
+	}
+	if checkpointer != nil {
+		checkpointer.update(offsets())
+		checkpointer.save()
+	}
+	if logCmdArgs.EmitOffsets {
+		emitOffsetCheckpoint(printer, offsets())
+	}
+	flushOutput()
+
+	printer.close()
+	if ctx.Err() != nil || isVerboseLoggingEnabled {
+		log.Status(sessionSummary(session.Stats()))
+	}
+	return nil
+}
+
+// newClient : livelogs client of a command, using the orchestrator of its flags. With --trust_host_keys the CLI trusts
+// the host key of a central livelogs agent it has never seen and warns about it, as ssh does.
+func newClient(cmd *cobra.Command, options livelogs.Options) (*livelogs.Client, error) {
+	options.OrchestratorUrl, _ = cmd.Flags().GetString(constants.ArgumentOrchestratorUrl)
+	options.CABundle, _ = cmd.Flags().GetString(constants.ArgumentCABundle)
+	options.Logger = clientLogger{}
+	options.TrustHostKeysOnFirstUse, _ = cmd.Flags().GetBool(constants.ArgumentTrustHostKeys)
+	return livelogs.NewClient(options)
 }
 
-func parseArguments(cmd *cobra.Command) models.LogsCommandArgs {
+// clientLogger shows the messages of the livelogs client on the terminal
+type clientLogger struct{}
+
+func (clientLogger) Info(message string) {
+	log.Success(message)
+}
+
+func (clientLogger) Warn(message string) {
+	log.Warn(message)
+}
+
+// newQuery : query of the livelogs client for the command arguments
+func newQuery(args *models.LogsCommandArgs, source livelogs.Source) livelogs.Query {
+	return livelogs.Query{
+		Env:            args.Env,
+		Org:            args.Org,
+		Account:        args.Account,
+		CloudProvider:  args.CloudProvider,
+		ServiceName:    args.ServiceName,
+		ComponentName:  args.ComponentName,
+		ComponentType:  args.ComponentType,
+		AsgName:        args.AsgName,
+		Since:          args.Since,
+		StartTime:      args.StartTime,
+		EndTime:        args.EndTime,
+		Timezone:       args.Timezone,
+		NoFollow:       args.NoFollow,
+		Ordered:        args.Ordered,
+		ReorderWindow:  args.ReorderWindow,
+		ShowTags:       args.ShowTags,
+		Level:          args.Level,
+		LevelMapping:   args.LevelMapping,
+		Filter:         args.Filter,
+		EncodedFilter:  args.EncodedFilter,
+		Source:         source,
+		ResumeOffsets:  args.ResumeOffsets,
+		Record:         args.Record,
+		Compress:       args.Compress,
+		Text:           textOptions(args),
+		LinuxOperation: args.LinuxOperation,
+	}
+}
+
+func parseArguments(cmd *cobra.Command) (models.LogsCommandArgs, error) {
+	if err := applyProfileAndEnvironment(cmd); err != nil {
+		return models.LogsCommandArgs{}, err
+	}
+
 	env, _ := cmd.Flags().GetString(constants.ArgumentEnv)
 	account, _ := cmd.Flags().GetString(constants.ArgumentAccount)
 	serviceName, _ := cmd.Flags().GetString(constants.ArgumentServiceName)
@@ -128,21 +288,46 @@ func parseArguments(cmd *cobra.Command) models.LogsCommandArgs {
 	startTime, _ := cmd.Flags().GetString(constants.ArgumentStartTime)
 	endTime, _ := cmd.Flags().GetString(constants.ArgumentEndTime)
 	since, _ := cmd.Flags().GetString(constants.ArgumentSince)
+	timezone, _ := cmd.Flags().GetString(constants.ArgumentTimezone)
+	noFollow, _ := cmd.Flags().GetBool(constants.ArgumentNoFollow)
+	resume, _ := cmd.Flags().GetString(constants.ArgumentResume)
+	resumeOffsetsString, _ := cmd.Flags().GetString(constants.ResumeOffsets)
+	emitOffsets, _ := cmd.Flags().GetBool(constants.EmitOffsets)
+	protocolVersion, _ := cmd.Flags().GetInt(constants.Protocol)
+	compression, _ := cmd.Flags().GetString(constants.Compression)
+	compress, _ := cmd.Flags().GetBool(constants.ArgumentCompress)
+	if !cmd.Flags().Changed(constants.ArgumentCompress) {
+		compress = since != "" || startTime != ""
+	}
 	linuxOperation, _ := cmd.Flags().GetString(constants.ArgumentLinuxOperation)
 	logSearchConfigString, _ := cmd.Flags().GetString(constants.LogSearchConfig)
-	showTags, _ := cmd.Flags().GetString(constants.ArgumentShowTags)
 	asgName, _ := cmd.Flags().GetString(constants.AsgName)
 	componentType, _ := cmd.Flags().GetString(constants.ArgumentComponentType)
+	record, _ := cmd.Flags().GetString(constants.ArgumentRecord)
+	encodedFilter, _ := cmd.Flags().GetString(constants.EncodedFilter)
 
+	var resumeOffsets map[int32]int64
+	if resumeOffsetsString != "" {
+		err := json.Unmarshal([]byte(resumeOffsetsString), &resumeOffsets)
+		if err != nil {
+			return models.LogsCommandArgs{}, invalidArguments(fmt.Errorf("error unmarshalling resume offsets %s: %w", resumeOffsetsString, err))
+		}
+	}
+
+	// The config is read from stdin, passing it inline is only kept for local clients of older versions
+	if logSearchConfigString == constants.LogSearchConfigFromStdin {
+		logSearchConfigString = readLogSearchConfigFromStdin()
+	}
 	var logSearchConfig = models.LogSearchConfig{}
 	if logSearchConfigString != "" {
 		err := json.Unmarshal([]byte(logSearchConfigString), &logSearchConfig)
 		if err != nil {
-			log.Debug(fmt.Sprintf("Error unmarshalling log search config: %s. Error: %v", logSearchConfigString, err))
+			log.Debug(fmt.Sprintf("Error unmarshalling log search config. Error: %v", err))
 		}
+		util.RegisterLogSearchConfigSecrets(logSearchConfig)
 	}
 
-	return models.LogsCommandArgs{
+	logsCommandArgs := models.LogsCommandArgs{
 		Env:             env,
 		Account:         account,
 		AsgName:         asgName,
@@ -153,475 +338,109 @@ func parseArguments(cmd *cobra.Command) models.LogsCommandArgs {
 		StartTime:       startTime,
 		EndTime:         endTime,
 		Since:           since,
+		Timezone:        timezone,
+		NoFollow:        noFollow,
+		Resume:          resume,
+		ResumeOffsets:   resumeOffsets,
+		EmitOffsets:     emitOffsets,
+		Protocol:        protocolVersion,
+		Compress:        compress,
+		Compression:     compression,
 		LinuxOperation:  linuxOperation,
 		LogSearchConfig: logSearchConfig,
-		ShowTags:        showTags,
 		ComponentType:   componentType,
+		Record:          record,
+		EncodedFilter:   encodedFilter,
 	}
+	parseRecordOutputArguments(cmd, &logsCommandArgs)
+	return logsCommandArgs, nil
 }
 
-func validateArguments(args *models.LogsCommandArgs, config *models.LogSearchConfig) {
-	if args.Since != "" {
-		sinceDuration, err := time.ParseDuration(args.Since)
-
-		if err != nil {
-			log.ErrorAndExit("Error in parsing since as duration: " + args.Since)
-		}
-
-		validateIfLogsAreAvailable(sinceDuration, config, args.ServiceName, args.ComponentName)
-	}
-
-	if args.StartTime != "" {
-		startTimeDuration := util.GetUtcTimeDuration(args.StartTime)
-		validateIfLogsAreAvailable(startTimeDuration, config, args.ServiceName, args.ComponentName)
-	}
-
-	if args.EndTime != "" {
-		endTimeDuration := util.GetUtcTimeDuration(args.EndTime)
-		validateIfLogsAreAvailable(endTimeDuration, config, args.ServiceName, args.ComponentName)
-	}
+// parseRecordOutputArguments : read the flags added by addRecordOutputFlags
+func parseRecordOutputArguments(cmd *cobra.Command, args *models.LogsCommandArgs) {
+	args.Ordered, _ = cmd.Flags().GetBool(constants.ArgumentOrdered)
+	args.ReorderWindow, _ = cmd.Flags().GetDuration(constants.ArgumentReorderWindow)
+	args.ShowTags, _ = cmd.Flags().GetString(constants.ArgumentShowTags)
+	args.Output, _ = cmd.Flags().GetString(constants.ArgumentOutput)
+	args.Sinks, _ = cmd.Flags().GetStringArray(constants.ArgumentSink)
+	args.Template, _ = cmd.Flags().GetString(constants.ArgumentTemplate)
+	args.Timestamps, _ = cmd.Flags().GetString(constants.ArgumentTimestamps)
+	args.Level, _ = cmd.Flags().GetString(constants.ArgumentLevel)
+	args.LevelMapping, _ = cmd.Flags().GetString(constants.ArgumentLevelMapping)
+	args.Filter, _ = cmd.Flags().GetString(constants.ArgumentFilter)
 }
 
-func validateIfLogsAreAvailable(duration time.Duration, config *models.LogSearchConfig, serviceName, componentName string) {
-	if duration < 0 {
-		log.ErrorAndExit("Future timestamp is not allowed")
-	}
-
-	if duration > time.Duration(config.MaxRetentionMinutes)*time.Minute {
-		log.ErrorAndExit(fmt.Sprintf("We store only past %v minutes data for livelogs for service: %s and component: %s, for more logs please use grafana %s", config.MaxRetentionMinutes, serviceName, componentName, config.LogSearchGrafanaUrl))
-	}
+// profileFlags can be set by a profile of the config file or by a LIVELOGS_<FLAG> environment variable
+var profileFlags = []string{
+	constants.ArgumentEnv,
+	constants.ArgumentOrg,
+	constants.ArgumentAccount,
+	constants.ArgumentCloudProvider,
+	constants.ArgumentServiceName,
+	constants.ArgumentComponentName,
+	constants.ArgumentShowTags,
+	constants.ArgumentOutput,
+	constants.ArgumentTimezone,
+	constants.ArgumentTrustHostKeys,
 }
 
-func getCommandForCentralLivelogsAgent(cmd *cobra.Command, args []string, linuxOperation string, logSearchConfig *models.LogSearchConfig) string {
-	flags := ""
-	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
-		flagValue := flag.Value.String()
-		if flagValue != "" && flagValue != "false" && flag.Name != "linux_operation" {
-			if strings.Contains(flagValue, " ") {
-				flagValue = "\"" + flagValue + "\""
-			}
-			flags += "--" + flag.Name + " " + flagValue + " "
-		}
-	})
-	command := constants.CentralLiveLogAgentName + " " + cmd.Use + " " + flags
-	if len(args) > 0 {
-		command += " " + args[0]
-		for _, arg := range args[1:] {
-			command += " " + arg
-		}
-	}
-
-	jsonString, err := json.Marshal(logSearchConfig)
-	if err != nil {
-		log.ErrorAndExit("Error in marshalling log search config: " + err.Error())
+// applyProfileAndEnvironment : set the flags not passed on the command line, from a LIVELOGS_<FLAG> environment
+// variable or else the selected profile, so that the precedence is flag > environment > profile > default
+func applyProfileAndEnvironment(cmd *cobra.Command) error {
+	profileName, _ := cmd.Flags().GetString(constants.ArgumentProfile)
+	if profileName == "" {
+		profileName = os.Getenv(constants.EnvLivelogsProfile)
 	}
 
-	command += " --" + constants.LogSearchConfig + " '" + string(jsonString) + "'"
-
-	if len(linuxOperation) > 0 {
-		command += " | " + linuxOperation
-	}
-	log.Debug("Central live log agent command: " + command)
-	return command
-}
-
-func getBrokersIpFromDns(hostname string) []string {
-	log.Debug("Resolving DNS for Kafka brokers from hostname: " + hostname)
-	var brokers []string
-	ips := util.GetIpsFromHost(hostname)
-	for _, ip := range ips {
-		brokers = append(brokers, fmt.Sprintf("%s:%s", ip, constants.KafkaBrokerPort))
-	}
-
-	log.Debug("Resolved Kafka brokers: " + strings.Join(brokers, ", "))
-	return brokers
-}
-
-func printLogsOnTerminal(dtags []byte, serviceName, hostname, message string) {
-	var text string
-	if dtags == nil || string(dtags) == constants.EmptyJSON {
-		text = fmt.Sprintf("%s\t%s\t%s", serviceName, hostname, message)
-	} else {
-		text = fmt.Sprintf("%s\t%s\t%s\t%s", serviceName, hostname, string(dtags), message)
-	}
-	if strings.Contains(strings.ToLower(message), "error") {
-		log.Error(text)
-	} else {
-		log.Info(text)
-	}
-}
-
-func processAsgLogs(consumerMsg *sarama.ConsumerMessage, args *models.LogsCommandArgs) {
-	var asgLogs = &protobuf.AsgLogs{}
-	if err := proto.Unmarshal(consumerMsg.Value, asgLogs); err != nil {
-		log.Debug(fmt.Sprintf("Failed to decode message value: %v Error: %v", consumerMsg.Value, err))
-		return
-	}
-
-	logsStruct := models.AsgLogsStruct{
-		AccountId:            asgLogs.AccountId,
-		AutoScalingGroupName: asgLogs.AutoScalingGroupName,
-		Details:              asgLogs.Details,
-		ActivityId:           asgLogs.ActivityId,
-		RequestId:            asgLogs.RequestId,
-		Progress:             asgLogs.Progress,
-		Event:                asgLogs.Event,
-		StatusCode:           asgLogs.StatusCode,
-		StatusMessage:        asgLogs.StatusMessage,
-		Description:          asgLogs.Description,
-		Cause:                asgLogs.Cause,
-		StartTime:            asgLogs.StartTime,
-		EndTime:              asgLogs.EndTime,
-		EC2InstanceId:        asgLogs.Ec2InstanceId,
-	}
-	if strings.Contains(strings.ToLower(args.AsgName), logsStruct.AutoScalingGroupName) {
-		jsonData, err := json.MarshalIndent(logsStruct, "", " ")
+	var profileValues map[string]string
+	if profileName != "" {
+		livelogsConfig, err := util.LoadConfig()
 		if err != nil {
-			fmt.Println("Error marshaling to JSON:", err)
-		} else {
-			fmt.Println(string(jsonData))
+			return fmt.Errorf("error in loading config: %w", err)
 		}
-	}
-}
-
-func processApplicationLogs(consumerMsg *sarama.ConsumerMessage, args *models.LogsCommandArgs, isLowerEnv bool, showTagsArray []string) {
-	var vectorLogs = &protobuf.VectorLogs{}
-	if err := proto.Unmarshal(consumerMsg.Value, vectorLogs); err != nil {
-		log.Debug("Failed to decode message value. Error: " + err.Error())
-		return
-	}
-
-	if args.ShowTags != "" {
-		for key := range vectorLogs.Ddtags {
-			if !isDdTagAllowed(key, showTagsArray) {
-				delete(vectorLogs.Ddtags, key)
-			}
-		}
-	}
-
-	ddtags, err := json.Marshal(vectorLogs.Ddtags)
-	if err != nil {
-		log.Debug("Failed to encode ddtags. Error: " + err.Error())
-		return
-	}
-
-	logsStruct := models.VectorLogsStruct{
-		Message:       vectorLogs.Message,
-		Hostname:      util.DereferenceString(vectorLogs.Hostname),
-		Env:           vectorLogs.Env,
-		ComponentName: vectorLogs.ComponentName,
-		Service:       vectorLogs.ServiceName,
-		Ddtags:        vectorLogs.Ddtags,
-	}
-
-	shouldPrint := !isLowerEnv || (args.ServiceName == "" && args.ComponentName == "") ||
-		(logsStruct.Service != "" && strings.EqualFold(logsStruct.Service, args.ServiceName) &&
-			(args.ComponentName == "" || (logsStruct.Service != "" && strings.EqualFold(logsStruct.ComponentName, args.ComponentName))))
-
-	if shouldPrint {
-		message := logsStruct.Message
-		if reflect.TypeOf(message).String() == "string" {
-			msg := message.(string)
-			printLogsOnTerminal(ddtags, logsStruct.Service, logsStruct.Hostname, msg)
-		} else {
-			msg, err := json.Marshal(message)
-			if err != nil {
-				log.Debug("Failed to decode message. Error: " + err.Error())
-				return
-			}
-			printLogsOnTerminal(ddtags, logsStruct.Service, logsStruct.Hostname, string(msg))
-		}
-	}
-}
-
-func loadSamaraConfig() *sarama.Config {
-	config := sarama.NewConfig()
-	config.Consumer.Return.Errors = true
-	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
-	config.Version = sarama.V2_8_0_0
-	return config
-}
-
-func topicExists(brokerAddresses []string, topicName string, config *sarama.Config) (bool, error) {
-	log.Debug(fmt.Sprintf("Checking if topic %s exists in Kafka", topicName))
-	adminClient, err := sarama.NewClusterAdmin(brokerAddresses, config)
-	if err != nil {
-		log.ErrorAndExit("Failed to create Kafka admin client. Error: " + err.Error())
-	}
-	defer adminClient.Close()
-
-	topics, err := adminClient.ListTopics()
-	if err != nil {
-		log.ErrorAndExit("Failed to list topics. Error: " + err.Error())
-	}
-
-	var topicNames []string
-	for key := range topics {
-		topicNames = append(topicNames, key)
-	}
-
-	log.Debug(fmt.Sprintf("Topics in Kafka: %v", topicNames))
-	_, exists := topics[topicName]
-	return exists, nil
-}
-
-func readFromKafka(args *models.LogsCommandArgs, logSearchConfig *models.LogSearchConfig) {
-	log.Debug("Reading logs from Kafka")
-
-	brokers := getBrokersIpFromDns(logSearchConfig.KafkaBrokerHost)
-	samaraConfig := loadSamaraConfig()
-
-	topicExists, err := topicExists(brokers, logSearchConfig.Topic, samaraConfig)
-
-	if err != nil {
-		log.ErrorAndExit("Failed to check if topic exists in Kafka: " + err.Error())
-	}
-
-	if !topicExists {
-		log.ErrorAndExit("env:" + args.Env + " service_name:" + args.ServiceName + " component_name:" + args.ComponentName + " is not onboarded on Log Central")
-	} else {
-		log.Debug(fmt.Sprintf("Topic: %s exists in Kafka", logSearchConfig.Topic))
-	}
-
-	var wg sync.WaitGroup
-	var closeOnce sync.Once
-	var closeMutex sync.Mutex
-	stopChan := make(chan struct{})
-	var terminationWG sync.WaitGroup
-
-	terminationWG.Add(1)
-	go func() {
-		defer terminationWG.Done()
-		<-stopChan
-		closeMutex.Lock()
-		closeOnce.Do(func() {
-			close(stopChan)
-			log.Debug("Termination signal received. Closing stopChan.")
-		})
-		closeMutex.Unlock()
-	}()
-
-	client, err := sarama.NewClient(brokers, samaraConfig)
-	if err != nil {
-		log.ErrorAndExit(fmt.Sprintf("Failed to create Kafka client: %v", err))
-	}
-
-	defer func() {
-		_ = client.Close()
-	}()
-
-	consumer, err := sarama.NewConsumer(brokers, samaraConfig)
-	if err != nil {
-		log.ErrorAndExit(fmt.Sprintf("Failed to create Kafka consumer: %v", err))
-	}
-
-	defer func() {
-		err := consumer.Close()
+		profile, err := livelogsConfig.Profile(profileName)
 		if err != nil {
-			log.ErrorAndExit(fmt.Sprintf("Failed to close Kafka consumer: %v", err))
+			return invalidArguments(err)
 		}
-	}()
-
-	partitionConsumers := make(map[string]sarama.PartitionConsumer)
-	partitions, err := consumer.Partitions(logSearchConfig.Topic)
-	if err != nil {
-		log.ErrorAndExit(fmt.Sprintf("Failed to get partitions for topic: %v", err))
+		profileValues = profile.Values()
 	}
 
-	for _, partition := range partitions {
-		var sinceOffsets int64
-		var partitionConsumer sarama.PartitionConsumer
-
-		if args.Since != "" {
-			duration, err := time.ParseDuration(args.Since)
-			if err != nil {
-				log.ErrorAndExit("Error in parsing duration for since: " + args.Since)
-			}
-
-			resultTime := time.Now().Add(-duration).UnixNano() / int64(time.Millisecond)
-			sinceOffsets, err = client.GetOffset(logSearchConfig.Topic, partition, resultTime)
-			if err != nil {
-				log.ErrorAndExit(fmt.Sprintf("Failed to fetch offset from timestamp: %v", err))
-			}
-
-			partitionConsumer, err = consumer.ConsumePartition(logSearchConfig.Topic, partition, sinceOffsets)
-			if err != nil {
-				log.ErrorAndExit(fmt.Sprintf("Failed to create partition consumer: %v", err))
-			}
-		} else if args.Since == "" && args.StartTime != "" {
-			startEpochTime := util.GetEpochTimeFromTimestamp(args.StartTime)
-			startOffsets, err := client.GetOffset(logSearchConfig.Topic, partition, startEpochTime)
-			if err != nil {
-				log.ErrorAndExit(fmt.Sprintf("Failed to fetch offset from timestamp: %v", err))
-			}
-
-			partitionConsumer, err = consumer.ConsumePartition(logSearchConfig.Topic, partition, startOffsets)
-			if err != nil {
-				log.ErrorAndExit(fmt.Sprintf("Failed to create partition consumer: %v", err))
-			}
-		} else {
-			partitionConsumer, err = consumer.ConsumePartition(logSearchConfig.Topic, partition, sarama.OffsetNewest)
-			if err != nil {
-				log.ErrorAndExit(fmt.Sprintf("Failed to create partition consumer: %v", err))
-			}
+	for _, name := range profileFlags {
+		if cmd.Flags().Changed(name) {
+			continue
 		}
-
-		key := fmt.Sprintf("%s-%d", logSearchConfig.Topic, partition)
-		partitionConsumers[key] = partitionConsumer
-
-		wg.Add(1)
-
-		var endOffsets int64
-
-		if args.Since == "" && args.EndTime != "" {
-			endEpochTime := util.GetEpochTimeFromTimestamp(args.EndTime)
-			endOffsets, err = client.GetOffset(logSearchConfig.Topic, partition, endEpochTime)
-			if err != nil {
-				log.ErrorAndExit(fmt.Sprintf("Failed to fetch offset from timestamp: %v", err))
-			}
+		environmentVariable := constants.EnvLivelogsPrefix + strings.ToUpper(name)
+		value, source := os.Getenv(environmentVariable), environmentVariable
+		if value == "" {
+			value, source = profileValues[name], "profile "+profileName
 		}
-
-		showTagsArray := strings.Split(args.ShowTags, ",")
-
-		go func(consumer sarama.PartitionConsumer, endOffsets int64) {
-			defer wg.Done()
-			for eachMessage := range consumer.Messages() {
-				if args.EndTime != "" && eachMessage.Offset >= endOffsets {
-					closeMutex.Lock()
-					closeOnce.Do(func() {
-						close(stopChan)
-					})
-					closeMutex.Unlock()
-					return
-				}
-
-				if args.ComponentType == "application" {
-					processApplicationLogs(eachMessage, args, logSearchConfig.IsLowerEnv, showTagsArray)
-				} else if args.ComponentType == "asg" {
-					processAsgLogs(eachMessage, args)
-				}
-			}
-			closeMutex.Lock()
-			closeOnce.Do(func() {
-				close(stopChan)
-				log.Debug("Termination signal received. Closing stopChan.")
-			})
-			closeMutex.Unlock()
-
-		}(partitionConsumer, endOffsets)
-	}
-
-	// Wait for the termination goroutine to complete
-	terminationWG.Wait()
-
-	for _, partitionConsumer := range partitionConsumers {
-		err := partitionConsumer.Close()
-		if err != nil {
-			log.ErrorAndExit(fmt.Sprintf("Failed to close partition consumer: %v", err))
+		if value == "" {
+			continue
 		}
-	}
-}
-
-func isDdTagAllowed(tag string, allowedTags []string) bool {
-	for _, s := range allowedTags {
-		if s == tag {
-			return true
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return invalidArguments(fmt.Errorf("invalid %s from %s: %w", name, source, err))
 		}
+		log.Debug(fmt.Sprintf("Using %s=%s from %s", name, value, source))
 	}
-	return false
+	return nil
 }
 
-func readFromCentralLivelogsAgent(command string, logSearchConfig models.LogSearchConfig, logsCommandArgs *models.LogsCommandArgs) {
-	log.Debug("Reading logs from central livelogs agent")
-
-	centralLiveLogsAgentIp := util.GetAnyRandomIpFromHost(logSearchConfig.LiveLogAgentHost)
-
-	if centralLiveLogsAgentIp == "" {
-		log.ErrorAndExit("Unable to resolve DNS of central livelogs agent host. Please connect to correct vpn")
-	}
-
-	log.Debug("Connecting to central livelogs agent IP: " + centralLiveLogsAgentIp)
-	remoteAddr := fmt.Sprintf("%s:%d", centralLiveLogsAgentIp, logSearchConfig.LiveLogAgentSshPort)
-	decryptedPem, err := encryption.Decrypt(logSearchConfig.LiveLogAgentSshPemKey, logSearchConfig.LiveLogAgentSecretKey, logSearchConfig.LiveLogAgentSecretIv)
-
-	if err != nil {
-		log.Debug("Failed to decrypt ssh key: " + err.Error())
-		log.ErrorAndExit("Failed to connect to central livelogs agent host.")
-	}
-
-	log.Success("Connecting to central livelogs agent...")
-
-	logSearchConfig.LiveLogAgentSshPemKey = decryptedPem
-
-	sshConfig := &ssh.ClientConfig{
-		User: logSearchConfig.LiveLogAgentSshUser,
-		Auth: []ssh.AuthMethod{
-			getPemAuth(logSearchConfig.LiveLogAgentSshPemKey),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         constants.CentralLiveLogAgentSshTimeout,
-	}
-
-	client, err := ssh.Dial("tcp", remoteAddr, sshConfig)
-	if err != nil {
-		log.ErrorAndExit("Failed to connect to central livelogs agent: " + err.Error())
-	}
-	defer client.Close()
-
-	session, err := client.NewSession()
-	if err != nil {
-		log.ErrorAndExit("Failed to create tcp session with central livelogs agent: " + err.Error())
-	}
-	defer session.Close()
-
-	stdoutPipe, err := session.StdoutPipe()
-	if err != nil {
-		log.ErrorAndExit("Failed to fetch data from central livelogs agent:" + err.Error())
-	}
-
-	liveLogsUser, err := os.Hostname()
-	if err != nil {
-		log.Debug("Error in fetching hostname: " + err.Error())
-		liveLogsUser = constants.EnvLivelogsUser
+// readLogSearchConfigFromStdin : the local livelogs client writes the log search config as a single line
+func readLogSearchConfigFromStdin() string {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Debug("Error reading log search config from stdin: " + err.Error())
 	}
-	envVars := fmt.Sprintf("%s=\"%s\"", constants.EnvLivelogsUser, liveLogsUser)
-	command = fmt.Sprintf("env %s %s", envVars, command)
-	log.Debug(fmt.Sprintf("User: [%s] is executing command: [%s] on central live log agent", liveLogsUser, command))
-	err = session.Start(command)
-	if err != nil {
-		log.ErrorAndExit("Command execution on central livelogs agent failed: " + err.Error())
-	}
-
-	go func() {
-		util.UserLogFunc(logsCommandArgs, logSearchConfig.Tenant)
-	}()
-
-	go func() {
-		reader := bufio.NewReader(stdoutPipe)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err != io.EOF {
-					log.Error("Error reading from central livelogs agent: " + err.Error())
-				}
-				break
-			}
-			fmt.Print(line)
-		}
-	}()
-
-	err = session.Wait()
-	if err != nil {
-		log.ErrorAndExit("Command execution on central livelogs agent failed: " + err.Error())
-	}
-
+	return strings.TrimSpace(line)
 }
 
-func getPemAuth(key string) ssh.AuthMethod {
-	signer, err := ssh.ParsePrivateKey([]byte(key))
+func getResumeCheckpointer(name, topic string) (*resumeCheckpointer, error) {
+	checkpointer, err := newResumeCheckpointer(name, topic)
 	if err != nil {
-		log.Debug("Fail to connect to central live log agent host. Unable to parse private key: " + err.Error())
-		log.ErrorAndExit("Failed to connect to central live log agent host")
+		return nil, fmt.Errorf("unable to resume: %w", err)
+	}
+	if len(checkpointer.nextOffsets()) > 0 {
+		log.Success(fmt.Sprintf("Resuming %q from its saved offsets", name))
 	}
-	return ssh.PublicKeys(signer)
+	return checkpointer, nil
 }
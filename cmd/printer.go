@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dream11/livelogs/app"
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/formatter"
+	"github.com/dream11/livelogs/pkg/logger"
+	"github.com/dream11/livelogs/pkg/protocol"
+	"github.com/dream11/livelogs/pkg/sink"
+)
+
+// recordPrinter delivers the records of a session to the sinks of the command arguments
+type recordPrinter struct {
+	args  *models.LogsCommandArgs
+	sinks []sink.Sink
+	// failingSinks marks the sinks whose last write failed, so that a failure is only reported once
+	failingSinks []bool
+	// frames is set when the records are sent to a local livelogs client instead of being printed
+	frames *protocol.Writer
+	closed bool
+}
+
+func newRecordPrinter(args *models.LogsCommandArgs) (*recordPrinter, error) {
+	terminalFormatter, err := formatter.New(textOptions(args))
+	if err != nil {
+		return nil, err
+	}
+
+	sinkSpecs := args.Sinks
+	if len(sinkSpecs) == 0 {
+		sinkSpecs = []string{constants.SinkTerminal}
+	}
+	var sinks []sink.Sink
+	for _, spec := range sinkSpecs {
+		recordSink, err := sink.Open(spec, terminalFormatter)
+		if err != nil {
+			for _, openedSink := range sinks {
+				_ = openedSink.Close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, recordSink)
+	}
+
+	return &recordPrinter{
+		args:         args,
+		sinks:        sinks,
+		failingSinks: make([]bool, len(sinks)),
+	}, nil
+}
+
+// textOptions : how records are rendered on the terminal
+func textOptions(args *models.LogsCommandArgs) formatter.Options {
+	return formatter.Options{
+		Output:     args.Output,
+		Template:   args.Template,
+		Timestamps: args.Timestamps,
+	}
+}
+
+// close : deliver what the sinks still buffer, only the first call closes them
+func (p *recordPrinter) close() {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for index, recordSink := range p.sinks {
+		if err := recordSink.Close(); err != nil {
+			log.Warn(fmt.Sprintf("Failed to close sink %s: %v", p.sinkName(index), err))
+		}
+	}
+}
+
+func (p *recordPrinter) sinkName(index int) string {
+	if len(p.args.Sinks) == 0 {
+		return constants.SinkTerminal
+	}
+	return p.args.Sinks[index]
+}
+
+// startFramedOutput : answer the protocol handshake of the local livelogs client, records are then sent as frames
+// unless the requested version is not understood, in which case the output stays text. Everything written after the
+// hello frame is compressed when a codec is agreed on, the returned function flushes it
+func startFramedOutput(ctx context.Context, p *recordPrinter, requestedVersion int, requestedCompression string) (func(), error) {
+	version := protocol.Negotiate(requestedVersion)
+	compression := ""
+	if version > 0 {
+		compression = protocol.NegotiateCompression(requestedCompression)
+	}
+	hello := protocol.Frame{Type: protocol.FrameHello, Version: version, Agent: app.App.Version, Compression: compression}
+	if err := protocol.NewWriter(os.Stdout).Write(hello); err != nil {
+		log.Debug("Failed to write hello frame: " + err.Error())
+		return func() {}, nil
+	}
+	if version == 0 {
+		return func() {}, nil
+	}
+
+	var output io.Writer = os.Stdout
+	flush := func() {}
+	if compression != "" {
+		compressedOutput, err := protocol.NewCompressedWriter(compression, os.Stdout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress output: %w", err)
+		}
+		logger.SetOutput(compressedOutput)
+		go compressedOutput.FlushEvery(ctx, constants.CompressionFlushInterval)
+		output = compressedOutput
+		flush = func() { _ = compressedOutput.Flush() }
+	}
+	p.frames = protocol.NewWriter(output)
+	return flush, nil
+}
+
+// printRecord : deliver a selected record to every sink
+func (p *recordPrinter) printRecord(record *models.LogRecord) {
+	if p.frames != nil {
+		if err := p.frames.Write(protocol.Frame{Type: protocol.FrameRecord, Record: record}); err != nil {
+			log.Debug("Failed to write record frame: " + err.Error())
+		}
+		return
+	}
+	for index, recordSink := range p.sinks {
+		err := recordSink.Write(record)
+		switch {
+		case err != nil && !p.failingSinks[index]:
+			log.Warn(fmt.Sprintf("Failed to write to sink %s: %v", p.sinkName(index), err))
+		case err != nil:
+			log.Debug(fmt.Sprintf("Failed to write to sink %s: %v", p.sinkName(index), err))
+		case p.failingSinks[index]:
+			log.Debug("Sink " + p.sinkName(index) + " recovered")
+		}
+		p.failingSinks[index] = err != nil
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/livelogs"
+	"github.com/dream11/livelogs/pkg/recording"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addRecordOutputFlags(replayCmd.Flags())
+	replayCmd.Flags().BoolP(constants.ArgumentOriginalSpeed, "", false, "Replay records at the pace they were consumed instead of as fast as possible")
+	replayCmd.Flags().BoolP(constants.ArgumentVerbose, "v", false, "verbose logging")
+	_ = replayCmd.Flags().MarkHidden(constants.ArgumentVerbose)
+	rootCmd.AddCommand(replayCmd)
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Print the Kafka records saved with logs --record",
+	Long:  "Print the Kafka records saved with logs --record, decoding, filtering and formatting them as the logs command does",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWithSessionTimeout(cmd, false, func(ctx context.Context, timer *sessionTimer) error {
+			return replayCmdHandler(ctx, cmd, args[0])
+		})
+	},
+}
+
+func replayCmdHandler(ctx context.Context, cmd *cobra.Command, path string) error {
+	isVerboseLoggingEnabled, _ := cmd.Flags().GetBool(constants.ArgumentVerbose)
+	if isVerboseLoggingEnabled {
+		log.EnableDebugMode()
+	}
+
+	reader, err := recording.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording %s: %w", path, err)
+	}
+	defer reader.Close()
+	metadata := reader.Metadata
+	log.Debug(fmt.Sprintf("Recording metadata: %+v", metadata))
+
+	var replayArgs models.LogsCommandArgs
+	parseRecordOutputArguments(cmd, &replayArgs)
+	printer, err := newRecordPrinter(&replayArgs)
+	if err != nil {
+		return invalidArguments(err)
+	}
+	defer printer.close()
+
+	originalSpeed, _ := cmd.Flags().GetBool(constants.ArgumentOriginalSpeed)
+	log.Success(fmt.Sprintf("Replaying topic %s recorded at %s", metadata.Topic, metadata.RecordedAt.UTC().Format(time.RFC3339)))
+	client, err := newClient(cmd, livelogs.Options{})
+	if err != nil {
+		return err
+	}
+	session, err := client.Replay(ctx, reader, newQuery(&replayArgs, livelogs.Source{}), originalSpeed)
+	if err != nil {
+		return err
+	}
+	for record := range session.Records {
+		printer.printRecord(record)
+	}
+	printer.close()
+
+	if ctx.Err() != nil || isVerboseLoggingEnabled {
+		log.Status(sessionSummary(session.Stats()))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/protocol"
+	"github.com/dream11/livelogs/pkg/state"
+	"github.com/dream11/livelogs/util"
+)
+
+// resumeCheckpointer persists the last processed offset of every partition of a resumable tail
+type resumeCheckpointer struct {
+	path  string
+	topic string
+
+	mu    sync.Mutex
+	state *state.State
+	dirty bool
+}
+
+func newResumeCheckpointer(name, topic string) (*resumeCheckpointer, error) {
+	livelogsDir, err := util.GetLivelogsDir()
+	if err != nil {
+		return nil, fmt.Errorf("unable to access livelogs directory: %w", err)
+	}
+	path, err := state.Path(livelogsDir, name)
+	if err != nil {
+		return nil, err
+	}
+	savedState, err := state.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug(fmt.Sprintf("Loaded resume state %s: %v", path, savedState.Topics[topic]))
+	return &resumeCheckpointer{path: path, topic: topic, state: savedState}, nil
+}
+
+// nextOffsets : offset to resume each partition from, empty on the first run
+func (c *resumeCheckpointer) nextOffsets() state.Offsets {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.NextOffsets(c.topic)
+}
+
+func (c *resumeCheckpointer) update(offsets state.Offsets) {
+	if len(offsets) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.Update(c.topic, offsets)
+	c.dirty = true
+}
+
+func (c *resumeCheckpointer) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return
+	}
+	if err := c.state.Save(c.path); err != nil {
+		log.Warn("Failed to save resume state: " + err.Error())
+		return
+	}
+	c.dirty = false
+}
+
+// run : save the offsets periodically until the context is done, source is polled for offsets when it is set
+func (c *resumeCheckpointer) run(ctx context.Context, source func() map[int32]int64) {
+	ticker := time.NewTicker(constants.OffsetCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if source != nil {
+				c.update(source())
+			}
+			c.save()
+		}
+	}
+}
+
+// deliveredOffsets is the last offset of every partition whose record reached the printer. Checkpoints are taken from it
+// rather than from what the source read, so that records still queued when the command stops are read again on resume.
+type deliveredOffsets struct {
+	mu      sync.Mutex
+	offsets map[int32]int64
+}
+
+func newDeliveredOffsets() *deliveredOffsets {
+	return &deliveredOffsets{offsets: map[int32]int64{}}
+}
+
+// add : advance the partition of a delivered record, text lines of a central livelogs agent carry no offset
+func (d *deliveredOffsets) add(record *models.LogRecord) {
+	if record.Topic == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.offsets[record.Partition]; !ok || record.Offset > last {
+		d.offsets[record.Partition] = record.Offset
+	}
+}
+
+func (d *deliveredOffsets) snapshot() map[int32]int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	offsets := make(map[int32]int64, len(d.offsets))
+	for partition, offset := range d.offsets {
+		offsets[partition] = offset
+	}
+	return offsets
+}
+
+// emitOffsetCheckpoints : stream the last processed offsets to the local livelogs client until the context is done
+func emitOffsetCheckpoints(ctx context.Context, printer *recordPrinter, offsets func() map[int32]int64) {
+	ticker := time.NewTicker(constants.OffsetCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			emitOffsetCheckpoint(printer, offsets())
+		}
+	}
+}
+
+func emitOffsetCheckpoint(printer *recordPrinter, offsets map[int32]int64) {
+	if len(offsets) == 0 {
+		return
+	}
+	if printer.frames != nil {
+		if err := printer.frames.Write(protocol.Frame{Type: protocol.FrameOffsets, Offsets: offsets}); err != nil {
+			log.Debug("Failed to write offsets frame: " + err.Error())
+		}
+		return
+	}
+	data, err := json.Marshal(offsets)
+	if err != nil {
+		log.Debug("Failed to encode offsets checkpoint: " + err.Error())
+		return
+	}
+	log.Output(constants.OffsetCheckpointPrefix + string(data))
+}
@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"errors"
+	"os"
+
 	"github.com/dream11/livelogs/app"
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/pkg/logger"
 	"github.com/spf13/cobra"
 )
 
@@ -10,14 +15,37 @@ var rootCmd = &cobra.Command{
 	Short:   "Check your service logs",
 	Long:    `Livelogs is a simple tool to check your service logs for any environments`,
 	Version: app.App.Version,
+	// Errors are printed by Execute, with a hint and an exit code of their own
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags and arguments are valid once here, a failing command does not need its usage
+		cmd.SilenceUsage = true
+		return nil
+	},
 }
 
+// Execute : run the command line, printing the error of a failed command with what to do about it and exiting with
+// the status of its kind
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		log.ErrorAndExit("Error with the command executed: " + err.Error())
+	err := rootCmd.Execute()
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, errInterrupted) {
+		log.Error(errorMessage(err))
+		if hint := remediationHint(err); hint != "" {
+			log.Info(hint)
+		}
 	}
+	logger.Flush()
+	os.Exit(exitCode(err))
 }
 
 func init() {
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return invalidArguments(err)
+	})
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().StringP(constants.ArgumentOrchestratorUrl, "", "", "URL of the livelogs orchestrator (Default is "+constants.EnvOrchestratorUrl+", else orchestrator.url of ~/.livelogs/config.yaml, else "+constants.CentralLiveLogAgentHost+")")
+	rootCmd.PersistentFlags().StringP(constants.ArgumentCABundle, "", "", "PEM bundle of additional CAs trusted for the livelogs orchestrator (Default is "+constants.EnvCABundle+", else orchestrator.ca_bundle of ~/.livelogs/config.yaml)")
 }
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/spf13/cobra"
+)
+
+// sessionTimer enforces the duration of a command, a zero deadline means the session never expires
+type sessionTimer struct {
+	mu          sync.Mutex
+	timeout     time.Duration
+	deadline    time.Time
+	maxDeadline time.Time
+	interactive bool
+	warned      bool
+	expired     bool
+}
+
+func newSessionTimer(timeout time.Duration, interactive bool) *sessionTimer {
+	timer := &sessionTimer{timeout: timeout, interactive: interactive}
+	if timeout > 0 {
+		timer.deadline = time.Now().Add(timeout)
+	}
+	return timer
+}
+
+// applyServerLimit : cap the session to the maximum duration allowed by the log search config, zero means no limit
+func (t *sessionTimer) applyServerLimit(maxSessionMinutes int) {
+	if maxSessionMinutes <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.maxDeadline = time.Now().Add(time.Duration(maxSessionMinutes) * time.Minute)
+	if t.deadline.IsZero() || t.deadline.After(t.maxDeadline) {
+		log.Debug(fmt.Sprintf("Session is limited to %d minutes by the server", maxSessionMinutes))
+		t.deadline = t.maxDeadline
+	}
+}
+
+// extend : move the deadline by one more timeout, without going past the server limit
+func (t *sessionTimer) extend() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.maxDeadline.IsZero() && !t.deadline.Before(t.maxDeadline) {
+		return false
+	}
+	extension := t.timeout
+	if extension <= 0 {
+		extension = constants.GlobalLogsCommandTimeout
+	}
+	t.deadline = t.deadline.Add(extension)
+	if !t.maxDeadline.IsZero() && t.deadline.After(t.maxDeadline) {
+		t.deadline = t.maxDeadline
+	}
+	t.warned = false
+	return true
+}
+
+func (t *sessionTimer) remaining() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.deadline.IsZero() {
+		return 0, false
+	}
+	return time.Until(t.deadline), true
+}
+
+func (t *sessionTimer) hasExpired() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.expired
+}
+
+// run : cancel the context once the deadline passes, warning shortly before it
+func (t *sessionTimer) run(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		remaining, limited := t.remaining()
+		if !limited {
+			continue
+		}
+		if remaining <= 0 {
+			t.mu.Lock()
+			t.expired = true
+			t.mu.Unlock()
+			cancel()
+			return
+		}
+
+		t.mu.Lock()
+		shouldWarn := !t.warned && remaining <= constants.SessionExpiryWarning
+		if shouldWarn {
+			t.warned = true
+		}
+		t.mu.Unlock()
+		if shouldWarn {
+			go t.warn(remaining)
+		}
+	}
+}
+
+func (t *sessionTimer) warn(remaining time.Duration) {
+	// Records may be streamed to stdout as ndjson or json, the exchange with the user stays on stderr
+	log.StatusWarn(fmt.Sprintf("Session expires in %v", remaining.Round(time.Second)))
+	if !t.interactive {
+		return
+	}
+
+	answer, err := log.AskStatus("Extend session? [y/N]")
+	if err != nil || !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		return
+	}
+	if !t.extend() {
+		log.StatusWarn("Session is already at the maximum duration allowed by the server and cannot be extended")
+		return
+	}
+	if remaining, _ := t.remaining(); remaining > 0 {
+		log.Status(fmt.Sprintf("Session extended, expires in %v", remaining.Round(time.Second)))
+	}
+}
+
+// runWithSessionTimeout : run a command handler until it completes, the session expires or the user interrupts it.
+// The handler is expected to return once it has cleaned up after the context is cancelled.
+func runWithSessionTimeout(cmd *cobra.Command, interactive bool, handler func(ctx context.Context, timer *sessionTimer) error) error {
+	timeout, _ := cmd.Flags().GetDuration(constants.ArgumentTimeout)
+	if timeout < 0 {
+		return invalidArguments(errors.New("timeout can not be negative"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	timer := newSessionTimer(timeout, interactive && isTerminal(os.Stdin))
+	go timer.run(ctx, cancel)
+
+	var interrupted atomic.Bool
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		interrupted.Store(true)
+		log.Status("Stopping... press Ctrl-C again to force exit")
+		cancel()
+		<-signals
+		os.Exit(constants.ExitCodeInterrupted)
+	}()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- handler(ctx, timer)
+	}()
+	var err error
+	select {
+	case err = <-result:
+		log.Debug("Operation completed")
+	case <-ctx.Done():
+		select {
+		case err = <-result:
+		case <-time.After(constants.ShutdownGracePeriod):
+			log.Debug("Timed out waiting for a graceful shutdown")
+		}
+	}
+
+	switch {
+	case timer.hasExpired():
+		return errTimeout
+	case interrupted.Load():
+		return errInterrupted
+	}
+	return err
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
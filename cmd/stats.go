@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dream11/livelogs/pkg/livelogs"
+)
+
+// sessionSummary : what a logs session read and printed, printed on shutdown
+func sessionSummary(stats livelogs.Stats) string {
+	partitions := make([]int32, 0, len(stats.Offsets))
+	for partition := range stats.Offsets {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	var partitionOffsets []string
+	for _, partition := range partitions {
+		partitionOffsets = append(partitionOffsets, fmt.Sprintf("%d:%d", partition, stats.Offsets[partition]))
+	}
+
+	summary := fmt.Sprintf("Session summary: duration %v, records read %d, records shown %d",
+		time.Since(stats.StartTime).Round(time.Second), stats.RecordsRead, stats.RecordsSelected)
+	if stats.Uncounted {
+		summary = fmt.Sprintf("Session summary: duration %v, records read and shown unavailable with the text output of the central livelogs agent",
+			time.Since(stats.StartTime).Round(time.Second))
+	}
+	if len(partitionOffsets) > 0 {
+		summary += ", last offsets (partition:offset) " + strings.Join(partitionOffsets, " ")
+	}
+	return summary
+}
@@ -3,29 +3,122 @@ package constants
 import "time"
 
 const (
-	ArgumentServiceName           = "service_name"
-	ArgumentComponentName         = "component_name"
-	ArgumentComponentType         = "component_type"
-	AsgName                       = "asg_name"
-	ArgumentEnv                   = "env"
-	ArgumentOrg                   = "org"
-	ArgumentCloudProvider         = "cloud_provider"
-	ArgumentAccount               = "account"
-	ArgumentStartTime             = "start_time"
-	ArgumentEndTime               = "end_time"
-	ArgumentSince                 = "since"
-	ArgumentLinuxOperation        = "linux_operation"
-	ArgumentShowTags              = "show_tags"
-	ArgumentVerbose               = "verbose"
-	LogSearchConfig               = "log_search_config"
-	GlobalLogsCommandTimeout      = 10 * time.Minute
-	EnvLivelogsUser               = "livelogs-user"
-	CentralLiveLogAgentSshTimeout = 5 * time.Second
-	CentralLiveLogAgentHost       = "http://log-central-orchestrator.dss-platform.com"
-	KafkaBrokerPort               = "9092"
-	EmptyJSON                     = "{}"
-	CentralLiveLogAgentName       = "central-livelogs"
-	AwsMetadataUrl                = "http://169.254.169.254/latest/meta-data"
-	GcpMetadataUrl                = "http://metadata.google.internal/computeMetadata/v1/"
-	LivelogsSetupScriptPath       = "scripts/livelogs_setup.sh"
+	ArgumentServiceName                   = "service_name"
+	ArgumentComponentName                 = "component_name"
+	ArgumentComponentType                 = "component_type"
+	AsgName                               = "asg_name"
+	ArgumentEnv                           = "env"
+	ArgumentOrg                           = "org"
+	ArgumentCloudProvider                 = "cloud_provider"
+	ArgumentAccount                       = "account"
+	ArgumentStartTime                     = "start_time"
+	ArgumentEndTime                       = "end_time"
+	ArgumentSince                         = "since"
+	ArgumentLinuxOperation                = "linux_operation"
+	ArgumentShowTags                      = "show_tags"
+	ArgumentVerbose                       = "verbose"
+	ArgumentOutput                        = "output"
+	ArgumentTemplate                      = "template"
+	ArgumentTimestamps                    = "timestamps"
+	ArgumentLevel                         = "level"
+	ArgumentLevelMapping                  = "level_mapping"
+	ArgumentFilter                        = "filter"
+	ArgumentTimezone                      = "timezone"
+	ArgumentNoFollow                      = "no_follow"
+	ArgumentNoFollowAlias                 = "no-follow"
+	ArgumentOrdered                       = "ordered"
+	ArgumentResume                        = "resume"
+	ResumeOffsets                         = "resume_offsets"
+	Protocol                              = "protocol"
+	ArgumentCompress                      = "compress"
+	Compression                           = "compression"
+	CompressionFlushInterval              = 200 * time.Millisecond
+	EmitOffsets                           = "emit_offsets"
+	DefaultResumeName                     = "default"
+	OffsetCheckpointPrefix                = "\x1elivelogs-offsets "
+	OffsetCheckpointInterval              = 2 * time.Second
+	KnownHostsFileName                    = "known_hosts"
+	LivelogsDirName                       = ".livelogs"
+	ArgumentReorderWindow                 = "reorder_window"
+	DefaultTimezone                       = "Asia/Kolkata"
+	EncodedFilter                         = "encoded_filter"
+	LogSearchConfig                       = "log_search_config"
+	LogSearchConfigFromStdin              = "-"
+	GlobalLogsCommandTimeout              = 10 * time.Minute
+	SessionExpiryWarning                  = time.Minute
+	ShutdownGracePeriod                   = 5 * time.Second
+	ExitCodeError                         = 1
+	ExitCodeUsage                         = 2
+	ExitCodeConfig                        = 3
+	ExitCodeDns                           = 4
+	ExitCodeAuth                          = 5
+	ExitCodeKafka                         = 6
+	ExitCodeAgent                         = 7
+	ExitCodeTimeout                       = 8
+	ExitCodeInterrupted                   = 130
+	ArgumentTimeout                       = "timeout"
+	EnvLivelogsUser                       = "livelogs-user"
+	CentralLiveLogAgentSshTimeout         = 5 * time.Second
+	CentralLiveLogAgentKeepAliveInterval  = 15 * time.Second
+	CentralLiveLogAgentKeepAliveTimeout   = 10 * time.Second
+	CentralLiveLogAgentInitialBackoff     = time.Second
+	CentralLiveLogAgentMaxBackoff         = 30 * time.Second
+	CentralLiveLogAgentMaxConnectAttempts = 8
+	CentralLiveLogAgentHost               = "http://log-central-orchestrator.dss-platform.com"
+	LogSearchConfigCacheTtl               = 15 * time.Minute
+	ArgumentOrchestratorUrl               = "orchestrator_url"
+	ArgumentCABundle                      = "ca_bundle"
+	EnvOrchestratorUrl                    = "LIVELOGS_ORCHESTRATOR_URL"
+	EnvCABundle                           = "LIVELOGS_CA_BUNDLE"
+	EnvLivelogsToken                      = "LIVELOGS_TOKEN"
+	EnvAllowInsecureToken                 = "LIVELOGS_ALLOW_INSECURE_TOKEN"
+	EnvOrchestratorHttps                  = "LIVELOGS_ORCHESTRATOR_HTTPS"
+	KafkaBrokerPort                       = "9092"
+	DnsCacheTtl                           = 30 * time.Second
+	DnsLookupTimeout                      = 5 * time.Second
+	ArgumentDnsServer                     = "dns_server"
+	ArgumentTrustHostKeys                 = "trust_host_keys"
+	EnvDnsServer                          = "LIVELOGS_DNS_SERVER"
+	BoundedQueryIdleTimeout               = 10 * time.Second
+	OrderedMergeFlushInterval             = 100 * time.Millisecond
+	DefaultReorderWindow                  = 2 * time.Second
+	RecordChannelSize                     = 256
+	EmptyJSON                             = "{}"
+	CentralLiveLogAgentName               = "central-livelogs"
+	AwsMetadataUrl                        = "http://169.254.169.254/latest/meta-data"
+	AwsMetadataTokenUrl                   = "http://169.254.169.254/latest/api/token"
+	AwsMetadataTokenTtlSeconds            = "60"
+	MetadataProbeTimeout                  = 2 * time.Second
+	ArgumentMode                          = "mode"
+	ArgumentProfile                       = "profile"
+	EnvLivelogsProfile                    = "LIVELOGS_PROFILE"
+	EnvLivelogsPrefix                     = "LIVELOGS_"
+	EnvLivelogsMode                       = "LIVELOGS_MODE"
+	ModeAuto                              = "auto"
+	ModeLocal                             = "local"
+	ModeCentral                           = "central"
+	ModeCacheTtl                          = 24 * time.Hour
+	ModeCacheUncertainTtl                 = time.Hour
+	ModeCacheFileName                     = "mode.json"
+	ArgumentSource                        = "source"
+	SourceKafka                           = "kafka"
+	SourceAgent                           = "agent"
+	SourceStdin                           = "stdin"
+	SourceFilePrefix                      = "file:"
+	MaxCapturedLineSize                   = 4 * 1024 * 1024
+	ArgumentSink                          = "sink"
+	SinkTerminal                          = "terminal"
+	DefaultSinkMaxFileSize                = 100 * 1024 * 1024
+	DefaultSinkMaxFiles                   = 5
+	SinkDialTimeout                       = 5 * time.Second
+	SinkWriteTimeout                      = 5 * time.Second
+	SinkRetryInterval                     = 5 * time.Second
+	SinkQueueSize                         = 10000
+	SinkBatchSize                         = 500
+	SinkFlushInterval                     = time.Second
+	SinkHttpTimeout                       = 10 * time.Second
+	ArgumentRecord                        = "record"
+	ArgumentOriginalSpeed                 = "original_speed"
+	GcpMetadataUrl                        = "http://metadata.google.internal/computeMetadata/v1/"
+	LivelogsSetupScriptPath               = "scripts/livelogs_setup.sh"
 )
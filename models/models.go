@@ -1,36 +1,58 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
 type Application struct {
 	Name    string
 	Version string
 }
 
 type VectorLogsStruct struct {
-	Ddsource      string      `json:"ddsource"`
-	Ddtags        interface{} `json:"ddtags"`
-	Hostname      string      `json:"hostname"`
-	Message       interface{} `json:"message"`
-	Service       string      `json:"service_name"`
-	SourceType    string      `json:"source_type"`
-	Env           string      `json:"env"`
-	ComponentName string      `json:"component_name"`
+	Ddsource      string            `json:"ddsource"`
+	Ddtags        interface{}       `json:"ddtags"`
+	Hostname      string            `json:"hostname"`
+	Message       interface{}       `json:"message"`
+	Service       string            `json:"service_name"`
+	SourceType    string            `json:"source_type"`
+	Env           string            `json:"env"`
+	ComponentName string            `json:"component_name"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Status        string            `json:"status,omitempty"`
+	Level         string            `json:"level"`
+	Extra         map[string]string `json:"extra,omitempty"`
 }
 
 type AsgLogsStruct struct {
-	AccountId            string `json:"accountId,omitempty"`
-	AutoScalingGroupName string `json:"autoScalingGroupName,omitempty"`
-	Details              string `json:"details,omitempty"`
-	ActivityId           string `json:"activityId,omitempty"`
-	RequestId            string `json:"requestId,omitempty"`
-	Progress             string `json:"progress,omitempty"`
-	Event                string `json:"event,omitempty"`
-	StatusCode           string `json:"statusCode,omitempty"`
-	StatusMessage        string `json:"statusMessage,omitempty"`
-	Description          string `json:"description,omitempty"`
-	Cause                string `json:"cause,omitempty"`
-	StartTime            string `json:"startTime,omitempty"`
-	EndTime              string `json:"endTime,omitempty"`
-	EC2InstanceId        string `json:"ec2InstanceId,omitempty"`
+	AccountId            string    `json:"accountId,omitempty"`
+	AutoScalingGroupName string    `json:"autoScalingGroupName,omitempty"`
+	Details              string    `json:"details,omitempty"`
+	ActivityId           string    `json:"activityId,omitempty"`
+	RequestId            string    `json:"requestId,omitempty"`
+	Progress             string    `json:"progress,omitempty"`
+	Event                string    `json:"event,omitempty"`
+	StatusCode           string    `json:"statusCode,omitempty"`
+	StatusMessage        string    `json:"statusMessage,omitempty"`
+	Description          string    `json:"description,omitempty"`
+	Cause                string    `json:"cause,omitempty"`
+	StartTime            string    `json:"startTime,omitempty"`
+	EndTime              string    `json:"endTime,omitempty"`
+	EC2InstanceId        string    `json:"ec2InstanceId,omitempty"`
+	Timestamp            time.Time `json:"timestamp"`
+}
+
+// LogRecord is a decoded application log or ASG event together with the partition and offset it was read from
+type LogRecord struct {
+	Topic       string            `json:"topic,omitempty"`
+	Partition   int32             `json:"partition"`
+	Offset      int64             `json:"offset"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Application *VectorLogsStruct `json:"application,omitempty"`
+	Asg         *AsgLogsStruct    `json:"asg,omitempty"`
+	// Text is a line of a central livelogs agent that only sends text, Application then holds it as a plain message
+	Text string `json:"-"`
 }
 
 type PayloadStruct struct {
@@ -74,6 +96,9 @@ type LogSearchConfig struct {
 	LiveLogAgentSshPort   int    `json:"liveLogAgentSshPemPort"`
 	IsLowerEnv            bool   `json:"isLowerEnv"`
 	Tenant                string `json:"tenant"`
+	MaxSessionMinutes     int    `json:"maxSessionMinutes"`
+	// LiveLogAgentHostKeys are the SHA256 fingerprints the central livelogs agent may present
+	LiveLogAgentHostKeys []string `json:"liveLogAgentHostKeyFingerprints"`
 }
 
 type LogsCommandArgs struct {
@@ -88,8 +113,50 @@ type LogsCommandArgs struct {
 	StartTime       string
 	EndTime         string
 	Since           string
+	Timezone        string
+	NoFollow        bool
+	Ordered         bool
+	Resume          string
+	ResumeOffsets   map[int32]int64
+	EmitOffsets     bool
+	Protocol        int
+	Compress        bool
+	Compression     string
+	Record          string
+	ReorderWindow   time.Duration
 	LinuxOperation  string
 	AllowedDdTags   bool
 	ShowTags        string
+	Output          string
+	Sinks           []string
+	Template        string
+	Timestamps      string
+	Level           string
+	LevelMapping    string
+	Filter          string
+	EncodedFilter   string
 	LogSearchConfig LogSearchConfig
 }
+
+const redactedValue = "[REDACTED]"
+
+// Redacted : copy of the config with the secret fields masked, safe to log
+func (c LogSearchConfig) Redacted() LogSearchConfig {
+	for _, field := range []*string{&c.LiveLogAgentSecretKey, &c.LiveLogAgentSecretIv, &c.LiveLogAgentSshPemKey} {
+		if *field != "" {
+			*field = redactedValue
+		}
+	}
+	return c
+}
+
+// Secrets : values of the secret fields
+func (c LogSearchConfig) Secrets() []string {
+	return []string{c.LiveLogAgentSecretKey, c.LiveLogAgentSecretIv, c.LiveLogAgentSshPemKey}
+}
+
+// String : print the config with its secret fields masked, including when nested in other values
+func (c LogSearchConfig) String() string {
+	type plain LogSearchConfig
+	return fmt.Sprintf("%+v", plain(c.Redacted()))
+}
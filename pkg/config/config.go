@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the config file inside the livelogs directory
+const FileName = "config.yaml"
+
+// Profile holds default values of the logs command flags, keyed by flag name in the config file
+type Profile struct {
+	Env           string `yaml:"env"`
+	Org           string `yaml:"org"`
+	Account       string `yaml:"account"`
+	CloudProvider string `yaml:"cloud_provider"`
+	ServiceName   string `yaml:"service_name"`
+	ComponentName string `yaml:"component_name"`
+	ShowTags      string `yaml:"show_tags"`
+	Output        string `yaml:"output"`
+	Timezone      string `yaml:"timezone"`
+	TrustHostKeys string `yaml:"trust_host_keys"`
+}
+
+// Orchestrator is the livelogs orchestrator serving log search configs
+type Orchestrator struct {
+	URL      string `yaml:"url"`
+	CABundle string `yaml:"ca_bundle"`
+	// AllowInsecureToken sends the token to an orchestrator URL that is not https, e.g. a local stand-in
+	AllowInsecureToken bool `yaml:"allow_insecure_token"`
+	// Https reaches the default orchestrator over https instead of http
+	Https bool `yaml:"https"`
+}
+
+// Config is the content of ~/.livelogs/config.yaml
+type Config struct {
+	Orchestrator Orchestrator       `yaml:"orchestrator"`
+	Profiles     map[string]Profile `yaml:"profiles"`
+}
+
+// Path : location of the config file inside the livelogs directory
+func Path(livelogsDir string) string {
+	return filepath.Join(livelogsDir, FileName)
+}
+
+// Load : read a config file, a missing file is an empty config
+func Load(path string) (*Config, error) {
+	config := &Config{Profiles: map[string]Profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file decodes to io.EOF
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+	return config, nil
+}
+
+// Profile : named profile, with the names of the available ones when it does not exist
+func (c *Config) Profile(name string) (Profile, error) {
+	profile, found := c.Profiles[name]
+	if !found {
+		names := make([]string, 0, len(c.Profiles))
+		for profileName := range c.Profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("profile %q not found, available profiles: [%s]", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// Values : values set by the profile, keyed by flag name
+func (p Profile) Values() map[string]string {
+	values := map[string]string{
+		"env":             p.Env,
+		"org":             p.Org,
+		"account":         p.Account,
+		"cloud_provider":  p.CloudProvider,
+		"service_name":    p.ServiceName,
+		"component_name":  p.ComponentName,
+		"show_tags":       p.ShowTags,
+		"output":          p.Output,
+		"timezone":        p.Timezone,
+		"trust_host_keys": p.TrustHostKeys,
+	}
+	for name, value := range values {
+		if value == "" {
+			delete(values, name)
+		}
+	}
+	return values
+}
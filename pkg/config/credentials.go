@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// CredentialsFileName is the name of the credentials file inside the livelogs directory
+const CredentialsFileName = "credentials.json"
+
+// Credentials are the tokens of the orchestrators the user logged in to, keyed by orchestrator URL
+type Credentials struct {
+	Tokens map[string]string `json:"tokens"`
+}
+
+// CredentialsPath : location of the credentials file inside the livelogs directory
+func CredentialsPath(livelogsDir string) string {
+	return filepath.Join(livelogsDir, CredentialsFileName)
+}
+
+// LoadCredentials : read a credentials file, a missing file holds no credentials
+func LoadCredentials(path string) (*Credentials, error) {
+	credentials := &Credentials{Tokens: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return credentials, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, credentials); err != nil {
+		return nil, err
+	}
+	if credentials.Tokens == nil {
+		credentials.Tokens = map[string]string{}
+	}
+	return credentials, nil
+}
+
+// Save : write the credentials atomically, readable by the user only
+func (c *Credentials) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,188 @@
+package configcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/encryption"
+)
+
+const (
+	dirName    = "cache"
+	configsDir = "configs"
+	// legacyKeyFileName is where the key used to be kept, next to the cache, it is removed when found
+	legacyKeyFileName = "cache.key"
+)
+
+// Key identifies the log search config of a query to an orchestrator
+type Key struct {
+	Orchestrator  string `json:"orchestrator"`
+	Env           string `json:"env"`
+	Org           string `json:"org"`
+	Account       string `json:"account"`
+	CloudProvider string `json:"cloudProvider"`
+	ServiceName   string `json:"serviceName"`
+	ComponentName string `json:"componentName"`
+	ComponentType string `json:"componentType"`
+	AsgName       string `json:"asgName"`
+}
+
+// Entry is a cached log search config
+type Entry struct {
+	Config    models.LogSearchConfig
+	FetchedAt time.Time
+	ExpiresAt time.Time
+}
+
+// IsFresh : whether the entry can be used without asking the orchestrator
+func (e *Entry) IsFresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// storedEntry is the file format of an entry, the secret fields of the config are sealed with the cache key
+type storedEntry struct {
+	Key       Key                    `json:"key"`
+	Config    models.LogSearchConfig `json:"config"`
+	Secrets   string                 `json:"secrets"`
+	FetchedAt time.Time              `json:"fetchedAt"`
+	ExpiresAt time.Time              `json:"expiresAt"`
+}
+
+type secrets struct {
+	SecretKey string `json:"secretKey"`
+	SecretIv  string `json:"secretIv"`
+	SshPemKey string `json:"sshPemKey"`
+}
+
+// Cache stores log search configs on disk
+type Cache struct {
+	dir string
+
+	// The key is only read from the keychain when an entry is read or written, runs that find no entry and store none
+	// never start the keychain tool
+	keyOnce sync.Once
+	key     []byte
+	keyErr  error
+}
+
+// Open : cache inside the livelogs directory. Its encryption key is created in the keychain on first use, reading and
+// writing entries fails where there is no keychain, as their secrets would then be readable by anyone reading the cache.
+func Open(livelogsDir string) (*Cache, error) {
+	dir := filepath.Join(livelogsDir, dirName)
+	if err := os.MkdirAll(filepath.Join(dir, configsDir), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(filepath.Join(dir, legacyKeyFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// encryptionKey : key of the cache, loaded from the keychain on first use
+func (c *Cache) encryptionKey() ([]byte, error) {
+	c.keyOnce.Do(func() {
+		if c.key, c.keyErr = loadOrCreateKey(); c.keyErr != nil {
+			c.keyErr = fmt.Errorf("failed to load cache key: %w", c.keyErr)
+		}
+	})
+	return c.key, c.keyErr
+}
+
+// Clear : remove every cached log search config
+func Clear(livelogsDir string) error {
+	return os.RemoveAll(filepath.Join(livelogsDir, dirName, configsDir))
+}
+
+// Get : cached entry of a key, nil when there is none or it can not be read
+func (c *Cache) Get(key Key) (*Entry, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored storedEntry
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid cache entry: %w", err)
+	}
+	if stored.Key != key {
+		return nil, nil
+	}
+	encryptionKey, err := c.encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	plainSecrets, err := encryption.Open(stored.Secrets, encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cache entry: %w", err)
+	}
+	var entrySecrets secrets
+	if err := json.Unmarshal(plainSecrets, &entrySecrets); err != nil {
+		return nil, fmt.Errorf("invalid cache entry secrets: %w", err)
+	}
+	config := stored.Config
+	config.LiveLogAgentSecretKey = entrySecrets.SecretKey
+	config.LiveLogAgentSecretIv = entrySecrets.SecretIv
+	config.LiveLogAgentSshPemKey = entrySecrets.SshPemKey
+	return &Entry{Config: config, FetchedAt: stored.FetchedAt, ExpiresAt: stored.ExpiresAt}, nil
+}
+
+// Put : cache the config of a key for ttl
+func (c *Cache) Put(key Key, config models.LogSearchConfig, ttl time.Duration) error {
+	encryptionKey, err := c.encryptionKey()
+	if err != nil {
+		return err
+	}
+	plainSecrets, err := json.Marshal(secrets{
+		SecretKey: config.LiveLogAgentSecretKey,
+		SecretIv:  config.LiveLogAgentSecretIv,
+		SshPemKey: config.LiveLogAgentSshPemKey,
+	})
+	if err != nil {
+		return err
+	}
+	sealedSecrets, err := encryption.Seal(plainSecrets, encryptionKey)
+	if err != nil {
+		return err
+	}
+	config.LiveLogAgentSecretKey, config.LiveLogAgentSecretIv, config.LiveLogAgentSshPemKey = "", "", ""
+
+	now := time.Now()
+	data, err := json.Marshal(storedEntry{Key: key, Config: config, Secrets: sealedSecrets, FetchedAt: now, ExpiresAt: now.Add(ttl)})
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Delete : remove the cached entry of a key, if any
+func (c *Cache) Delete(key Key) error {
+	err := os.Remove(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (c *Cache) path(key Key) string {
+	data, _ := json.Marshal(key)
+	sum := sha256.Sum256(data)
+	return filepath.Join(c.dir, configsDir, hex.EncodeToString(sum[:])+".json")
+}
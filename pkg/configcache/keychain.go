@@ -0,0 +1,114 @@
+package configcache
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dream11/livelogs/pkg/encryption"
+)
+
+// The cache key is kept in the keychain of the OS rather than next to the cache, so that reading the cache directory
+// is not enough to decrypt the secrets it holds: the login keychain on macOS, the Secret Service of the desktop on Linux
+
+const (
+	keychainService = "livelogs"
+	keychainAccount = "config-cache"
+	keychainLabel   = "livelogs config cache key"
+	keychainTimeout = 5 * time.Second
+)
+
+// errNoKeychain is returned where there is no keychain to keep the key in, configs are then not cached
+var errNoKeychain = errors.New("no keychain available")
+
+// errKeyNotFound is returned when the keychain answers that it holds no key yet
+var errKeyNotFound = errors.New("cache key not found in keychain")
+
+// loadOrCreateKey : key of the cache from the keychain, created on first use. A key is only created when the keychain
+// reports that it has none, any other failure must not replace a stored key, that would orphan every cached entry.
+func loadOrCreateKey() ([]byte, error) {
+	encoded, err := keychainGet()
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != encryption.KeySize {
+			return nil, errors.New("invalid cache key in keychain")
+		}
+		return key, nil
+	}
+	if !errors.Is(err, errKeyNotFound) {
+		return nil, err
+	}
+
+	key, err := encryption.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := keychainSet(hex.EncodeToString(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// keychainGet : stored key, errKeyNotFound when the keychain tool reports that there is none
+func keychainGet() (string, error) {
+	var encoded string
+	var err error
+	var exitError *exec.ExitError
+	switch runtime.GOOS {
+	case "darwin":
+		encoded, err = runKeychainTool("", "security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+		// security exits with errSecItemNotFound
+		if errors.As(err, &exitError) && exitError.ExitCode() == 44 {
+			return "", errKeyNotFound
+		}
+	case "linux":
+		encoded, err = runKeychainTool("", "secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+		// secret-tool exits with 1 and prints nothing when there is no such secret, and an error message otherwise
+		if errors.As(err, &exitError) && exitError.ExitCode() == 1 && len(strings.TrimSpace(string(exitError.Stderr))) == 0 {
+			return "", errKeyNotFound
+		}
+	default:
+		return "", errNoKeychain
+	}
+	return encoded, err
+}
+
+// keychainSet : store the key, handing it to the keychain tool on stdin so that it never shows up in ps
+func keychainSet(encodedKey string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		command := fmt.Sprintf("add-generic-password -U -s %s -a %s -l %q -w %s\n", keychainService, keychainAccount, keychainLabel, encodedKey)
+		_, err = runKeychainTool(command, "security", "-i")
+	case "linux":
+		_, err = runKeychainTool(encodedKey, "secret-tool", "store", "--label", keychainLabel, "service", keychainService, "account", keychainAccount)
+	default:
+		err = errNoKeychain
+	}
+	return err
+}
+
+func runKeychainTool(stdin string, name string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s not found", errNoKeychain, name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keychainTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && len(exitError.Stderr) > 0 {
+			return "", fmt.Errorf("%s %s failed: %w: %s", name, args[0], err, strings.TrimSpace(string(exitError.Stderr)))
+		}
+		return "", fmt.Errorf("%s %s failed: %w", name, args[0], err)
+	}
+	return string(output), nil
+}
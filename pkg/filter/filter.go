@@ -0,0 +1,253 @@
+package filter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dream11/livelogs/pkg/severity"
+)
+
+const (
+	nodeAnd     = "and"
+	nodeOr      = "or"
+	nodeNot     = "not"
+	nodeCompare = "compare"
+)
+
+// Fields gives the filter access to the fields of a record, names are like message, level, ddtags.region or extra.trace_id
+type Fields interface {
+	Lookup(name string) (string, bool)
+}
+
+// FieldsFunc adapts a function to the Fields interface
+type FieldsFunc func(name string) (string, bool)
+
+// Lookup : value of the named field
+func (f FieldsFunc) Lookup(name string) (string, bool) {
+	return f(name)
+}
+
+// node is the serializable syntax tree of a filter expression
+type node struct {
+	Kind       string  `json:"kind"`
+	Children   []*node `json:"children,omitempty"`
+	Field      string  `json:"field,omitempty"`
+	Comparator string  `json:"comparator,omitempty"`
+	Value      string  `json:"value,omitempty"`
+	RegexFlags string  `json:"regexFlags,omitempty"`
+	IsRegex    bool    `json:"isRegex,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Filter is a compiled filter expression, a nil Filter matches every record
+type Filter struct {
+	root *node
+}
+
+// Parse : compile an expression like `level>=warn and ddtags.region == "ap-south-1" and message ~ /timeout/i`
+func Parse(expression string) (*Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", next.value, next.position)
+	}
+
+	if err := root.compile(); err != nil {
+		return nil, err
+	}
+	return &Filter{root: root}, nil
+}
+
+// Encode : serialize the compiled expression so that it can be passed to the central livelogs agent as data
+func (f *Filter) Encode() (string, error) {
+	if f == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(f.root)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Decode : rebuild a filter serialized with Encode
+func Decode(encoded string) (*Filter, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encoded filter: %w", err)
+	}
+	root := &node{}
+	if err := json.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("invalid encoded filter: %w", err)
+	}
+	if err := root.compile(); err != nil {
+		return nil, err
+	}
+	return &Filter{root: root}, nil
+}
+
+// Match : true when the record satisfies the expression
+func (f *Filter) Match(fields Fields) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.evaluate(fields)
+}
+
+func (n *node) compile() error {
+	switch n.Kind {
+	case nodeAnd, nodeOr:
+		if len(n.Children) == 0 {
+			return fmt.Errorf("%s expression without operands", n.Kind)
+		}
+	case nodeNot:
+		if len(n.Children) != 1 {
+			return fmt.Errorf("not expression needs exactly one operand")
+		}
+	case nodeCompare:
+		if n.Field == "" {
+			return fmt.Errorf("comparison without a field")
+		}
+		if n.IsRegex || n.Comparator == "~" || n.Comparator == "!~" {
+			pattern := n.Value
+			if flags := regexFlags(n.RegexFlags); flags != "" {
+				pattern = "(?" + flags + ")" + pattern
+			}
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid regex /%s/: %w", n.Value, err)
+			}
+			n.regex = regex
+		}
+	default:
+		return fmt.Errorf("unknown expression kind %q", n.Kind)
+	}
+
+	for _, child := range n.Children {
+		if err := child.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *node) evaluate(fields Fields) bool {
+	switch n.Kind {
+	case nodeAnd:
+		for _, child := range n.Children {
+			if !child.evaluate(fields) {
+				return false
+			}
+		}
+		return true
+	case nodeOr:
+		for _, child := range n.Children {
+			if child.evaluate(fields) {
+				return true
+			}
+		}
+		return false
+	case nodeNot:
+		return !n.Children[0].evaluate(fields)
+	default:
+		return n.compare(fields)
+	}
+}
+
+func (n *node) compare(fields Fields) bool {
+	actual, exists := fields.Lookup(n.Field)
+	switch n.Comparator {
+	case "":
+		return exists && actual != ""
+	case "~":
+		return exists && n.regex.MatchString(actual)
+	case "!~":
+		return !exists || !n.regex.MatchString(actual)
+	case "==":
+		if n.regex != nil {
+			return exists && n.regex.MatchString(actual)
+		}
+		return exists && n.order(actual) == 0
+	case "!=":
+		if n.regex != nil {
+			return !exists || !n.regex.MatchString(actual)
+		}
+		return !exists || n.order(actual) != 0
+	}
+
+	if !exists {
+		return false
+	}
+	order := n.order(actual)
+	switch n.Comparator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+// order : compares the record value with the expression value, levels by severity, numbers numerically and everything else as text
+func (n *node) order(actual string) int {
+	if n.Field == "level" || n.Field == "status" {
+		actualLevel, actualOk := severity.Parse(actual)
+		expectedLevel, expectedOk := severity.Parse(n.Value)
+		if actualOk && expectedOk {
+			return int(actualLevel) - int(expectedLevel)
+		}
+	}
+
+	actualNumber, actualErr := strconv.ParseFloat(actual, 64)
+	expectedNumber, expectedErr := strconv.ParseFloat(n.Value, 64)
+	if actualErr == nil && expectedErr == nil {
+		switch {
+		case actualNumber < expectedNumber:
+			return -1
+		case actualNumber > expectedNumber:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	if n.Field == "level" || n.Field == "status" {
+		return strings.Compare(strings.ToLower(actual), strings.ToLower(n.Value))
+	}
+	return strings.Compare(actual, n.Value)
+}
+
+// regexFlags : keeps the i, m and s flags supported by Go regular expressions
+func regexFlags(flags string) string {
+	var supported strings.Builder
+	for _, flag := range "ims" {
+		if strings.ContainsRune(flags, flag) {
+			supported.WriteRune(flag)
+		}
+	}
+	return supported.String()
+}
@@ -0,0 +1,210 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func fields(values map[string]string) Fields {
+	return FieldsFunc(func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	})
+}
+
+func TestMatch(t *testing.T) {
+	record := fields(map[string]string{
+		"message":        "Request TIMEOUT after 30s",
+		"level":          "warn",
+		"status":         "warning",
+		"ddtags.region":  "ap-south-1",
+		"extra.trace_id": "abc",
+		"extra.empty":    "",
+		"extra.latency":  "120",
+		"extra.user":     "José",
+	})
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`level == warn`, true},
+		{`level = warn`, true},
+		{`level >= info`, true},
+		{`level >= error`, false},
+		{`level < error`, true},
+		{`status >= warn`, true},
+		{`ddtags.region == "ap-south-1"`, true},
+		{`ddtags.region != 'ap-south-1'`, false},
+		{`ddtags.zone != "a"`, true},
+		{`ddtags.zone == "a"`, false},
+		{`extra.latency > 99`, true},
+		{`extra.latency > 1000`, false},
+		{`extra.latency <= 120.0`, true},
+		{`extra.trace_id`, true},
+		{`extra.empty`, false},
+		{`extra.missing`, false},
+		{`extra.user == José`, true},
+		{`extra.user == "José"`, true},
+		{`extra.user ~ /^jos/i`, true},
+
+		// Regex flags
+		{`message ~ /timeout/`, false},
+		{`message ~ /timeout/i`, true},
+		{`message !~ /timeout/i`, false},
+		{`message == /TIME.*30s$/`, true},
+		{`message != /TIME.*30s$/`, false},
+		{`extra.missing !~ /x/`, true},
+		{`message ~ /a\/b/`, false},
+
+		// Precedence: not binds tighter than and, and tighter than or
+		{`level == error or level == warn and extra.trace_id`, true},
+		{`level == error or level == warn and extra.missing`, false},
+		{`(level == error or level == warn) and extra.missing`, false},
+		{`level == error and level == warn or extra.trace_id`, true},
+		{`not level == error and extra.trace_id`, true},
+		{`not (level == warn and extra.trace_id)`, false},
+		{`!extra.missing && extra.trace_id || level == error`, true},
+		{`not not extra.trace_id`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			f, err := Parse(test.expression)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", test.expression, err)
+			}
+			if got := f.Match(record); got != test.want {
+				t.Errorf("Match(%q) = %v, want %v", test.expression, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{`level == `, "expected a value after == at position 9"},
+		{`level == warn and`, "unexpected end of expression"},
+		{`(level == warn`, "expected ) at position 14"},
+		{`level == warn)`, `unexpected ")" at position 13`},
+		{`== warn`, `expected a field name at position 0, found "=="`},
+		{`message == "open`, "unterminated string starting at position 11"},
+		{`message ~ /open`, "unterminated regex starting at position 10"},
+		{`message ~ /(/`, "invalid regex /(/"},
+		{`level == warn $`, "unexpected character '$' at position 14"},
+		{`extra.user == José and €`, "unexpected character '€' at position 24"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := Parse(test.expression)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want an error", test.expression)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Parse(%q) = %q, want it to contain %q", test.expression, err, test.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeUnicode(t *testing.T) {
+	tokens, err := tokenize(`extra.città == naïve`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []token{
+		{kind: tokenIdentifier, value: "extra.città", position: 0},
+		{kind: tokenOperator, value: "==", position: 13},
+		{kind: tokenIdentifier, value: "naïve", position: 16},
+		{kind: tokenEOF, position: 22},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("tokenize = %+v, want %+v", tokens, want)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %d = %+v, want %+v", i, tokens[i], want[i])
+		}
+	}
+}
+
+func TestEmptyFilter(t *testing.T) {
+	f, err := Parse("   ")
+	if err != nil || f != nil {
+		t.Fatalf("Parse of a blank expression = %v, %v, want nil, nil", f, err)
+	}
+	if !f.Match(fields(nil)) {
+		t.Error("a nil filter must match every record")
+	}
+	encoded, err := f.Encode()
+	if err != nil || encoded != "" {
+		t.Errorf("Encode of a nil filter = %q, %v, want empty", encoded, err)
+	}
+	if decoded, err := Decode(""); err != nil || decoded != nil {
+		t.Errorf("Decode of an empty string = %v, %v, want nil, nil", decoded, err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	records := []Fields{
+		fields(map[string]string{"message": "Timeout", "level": "error", "extra.trace_id": "abc"}),
+		fields(map[string]string{"message": "ok", "level": "info"}),
+		fields(map[string]string{"message": "slow", "level": "warn", "ddtags.region": "ap-south-1"}),
+		fields(nil),
+	}
+	expressions := []string{
+		`level >= warn`,
+		`message ~ /timeout/i and extra.trace_id`,
+		`not (ddtags.region == "ap-south-1" or level == info)`,
+		`message == /^s/ || message != "ok"`,
+	}
+
+	for _, expression := range expressions {
+		t.Run(expression, func(t *testing.T) {
+			f, err := Parse(expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := f.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			for i, record := range records {
+				if got, want := decoded.Match(record), f.Match(record); got != want {
+					t.Errorf("record %d: decoded filter matched %v, parsed filter %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    string
+	}{
+		{"not base64", "%%%", "invalid encoded filter"},
+		{"not json", "bm90IGpzb24", "invalid encoded filter"},
+		{"unknown kind", "eyJraW5kIjoieG9yIn0", `unknown expression kind "xor"`},
+		{"and without operands", "eyJraW5kIjoiYW5kIn0", "and expression without operands"},
+		{"bad regex", "eyJraW5kIjoiY29tcGFyZSIsImZpZWxkIjoibWVzc2FnZSIsImNvbXBhcmF0b3IiOiJ-IiwidmFsdWUiOiIoIn0", "invalid regex"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Decode(test.encoded)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Decode(%q) = %v, want an error containing %q", test.encoded, err, test.want)
+			}
+		})
+	}
+}
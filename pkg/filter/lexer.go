@@ -0,0 +1,153 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenRegex
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+)
+
+type token struct {
+	kind     tokenKind
+	value    string
+	flags    string
+	position int
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "<", ">", "~", "!", "="}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	position := 0
+	for position < len(input) {
+		char, size := utf8.DecodeRuneInString(input[position:])
+		switch {
+		case unicode.IsSpace(char):
+			position += size
+		case char == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, value: "(", position: position})
+			position++
+		case char == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, value: ")", position: position})
+			position++
+		case char == '"' || char == '\'':
+			value, next, err := readQuoted(input, position)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, position: position})
+			position = next
+		case char == '/' && expectsValue(tokens):
+			pattern, flags, next, err := readRegex(input, position)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenRegex, value: pattern, flags: flags, position: position})
+			position = next
+		case isDigit(char) || (char == '-' && position+1 < len(input) && isDigit(rune(input[position+1]))):
+			start := position
+			position++
+			for position < len(input) && (isDigit(rune(input[position])) || input[position] == '.') {
+				position++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: input[start:position], position: start})
+		case isIdentifierChar(char):
+			start := position
+			for position < len(input) {
+				next, nextSize := utf8.DecodeRuneInString(input[position:])
+				if !isIdentifierChar(next) {
+					break
+				}
+				position += nextSize
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, value: input[start:position], position: start})
+		default:
+			operator := matchOperator(input[position:])
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", char, position)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: operator, position: position})
+			position += len(operator)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, position: len(input)}), nil
+}
+
+// expectsValue : a slash starts a regex only right after a comparison operator
+func expectsValue(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == tokenOperator && isComparator(last.value)
+}
+
+func readQuoted(input string, start int) (string, int, error) {
+	quote := input[start]
+	var builder strings.Builder
+	for position := start + 1; position < len(input); position++ {
+		char := input[position]
+		switch {
+		case char == '\\' && position+1 < len(input):
+			position++
+			builder.WriteByte(input[position])
+		case char == quote:
+			return builder.String(), position + 1, nil
+		default:
+			builder.WriteByte(char)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string starting at position %d", start)
+}
+
+func readRegex(input string, start int) (string, string, int, error) {
+	var builder strings.Builder
+	for position := start + 1; position < len(input); position++ {
+		char := input[position]
+		switch {
+		case char == '\\' && position+1 < len(input) && input[position+1] == '/':
+			position++
+			builder.WriteByte('/')
+		case char == '/':
+			flagsEnd := position + 1
+			for flagsEnd < len(input) && input[flagsEnd] < utf8.RuneSelf && unicode.IsLetter(rune(input[flagsEnd])) {
+				flagsEnd++
+			}
+			return builder.String(), input[position+1 : flagsEnd], flagsEnd, nil
+		default:
+			builder.WriteByte(char)
+		}
+	}
+	return "", "", 0, fmt.Errorf("unterminated regex starting at position %d", start)
+}
+
+func matchOperator(input string) string {
+	for _, operator := range operators {
+		if strings.HasPrefix(input, operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// isDigit : ASCII digits only, numbers are parsed with strconv which does not accept other scripts
+func isDigit(char rune) bool {
+	return char >= '0' && char <= '9'
+}
+
+func isIdentifierChar(char rune) bool {
+	return unicode.IsLetter(char) || unicode.IsDigit(char) || char == '_' || char == '.' || char == '-' || char == ':' || char == '@'
+}
@@ -0,0 +1,133 @@
+package filter
+
+import "fmt"
+
+type parser struct {
+	tokens   []token
+	position int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	current := p.tokens[p.position]
+	if current.kind != tokenEOF {
+		p.position++
+	}
+	return current
+}
+
+func (p *parser) isKeyword(current token, keyword, symbol string) bool {
+	return (current.kind == tokenIdentifier && current.value == keyword) || (current.kind == tokenOperator && current.value == symbol)
+}
+
+func (p *parser) parseOr() (*node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*node{left}
+	for p.isKeyword(p.peek(), "or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &node{Kind: nodeOr, Children: children}, nil
+}
+
+func (p *parser) parseAnd() (*node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []*node{left}
+	for p.isKeyword(p.peek(), "and", "&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &node{Kind: nodeAnd, Children: children}, nil
+}
+
+func (p *parser) parseNot() (*node, error) {
+	if p.isKeyword(p.peek(), "not", "!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &node{Kind: nodeNot, Children: []*node{operand}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (*node, error) {
+	current := p.next()
+	switch current.kind {
+	case tokenLeftParen:
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRightParen {
+			return nil, fmt.Errorf("expected ) at position %d", closing.position)
+		}
+		return expression, nil
+	case tokenIdentifier:
+		return p.parseComparison(current)
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("expected a field name at position %d, found %q", current.position, current.value)
+	}
+}
+
+func (p *parser) parseComparison(field token) (*node, error) {
+	comparison := &node{Kind: nodeCompare, Field: field.value}
+	operator := p.peek()
+	if operator.kind != tokenOperator || !isComparator(operator.value) {
+		// A bare field matches records where it is present and not empty
+		return comparison, nil
+	}
+	p.next()
+
+	comparison.Comparator = operator.value
+	if comparison.Comparator == "=" {
+		comparison.Comparator = "=="
+	}
+
+	value := p.next()
+	switch value.kind {
+	case tokenString, tokenNumber, tokenIdentifier:
+		comparison.Value = value.value
+	case tokenRegex:
+		comparison.Value = value.value
+		comparison.RegexFlags = value.flags
+		comparison.IsRegex = true
+	default:
+		return nil, fmt.Errorf("expected a value after %s at position %d", operator.value, value.position)
+	}
+	return comparison, nil
+}
+
+func isComparator(operator string) bool {
+	switch operator {
+	case "==", "=", "!=", "<", "<=", ">", ">=", "~", "!~":
+		return true
+	}
+	return false
+}
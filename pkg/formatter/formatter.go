@@ -0,0 +1,284 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+)
+
+const (
+	OutputText   = "text"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+	OutputLogfmt = "logfmt"
+	OutputRaw    = "raw"
+)
+
+const (
+	TimestampRFC3339     = "rfc3339"
+	TimestampEpochMillis = "epoch_millis"
+	TimestampRelative    = "relative"
+)
+
+// SupportedOutputs lists every value accepted by the --output flag
+var SupportedOutputs = []string{OutputText, OutputJSON, OutputNDJSON, OutputLogfmt, OutputRaw}
+
+// SupportedTimestamps lists every value accepted by the --timestamps flag
+var SupportedTimestamps = []string{TimestampRFC3339, TimestampEpochMillis, TimestampRelative}
+
+// Options controls how records are rendered
+type Options struct {
+	Output     string
+	Template   string
+	Timestamps string
+}
+
+// Formatter renders decoded log records in the requested output mode
+type Formatter struct {
+	output     string
+	timestamps string
+	template   *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"tag": func(tags interface{}, key string) string {
+		if tagMap, ok := tags.(map[string]string); ok {
+			return tagMap[key]
+		}
+		return ""
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// New : create a formatter for the given options, a non-empty template takes precedence over the output mode
+func New(options Options) (*Formatter, error) {
+	output := options.Output
+	if output == "" {
+		output = OutputText
+	}
+	if !isSupported(output, SupportedOutputs) {
+		return nil, fmt.Errorf("unsupported output %q, supported outputs are: %s", output, strings.Join(SupportedOutputs, ", "))
+	}
+	if options.Timestamps != "" && !isSupported(options.Timestamps, SupportedTimestamps) {
+		return nil, fmt.Errorf("unsupported timestamps format %q, supported formats are: %s", options.Timestamps, strings.Join(SupportedTimestamps, ", "))
+	}
+
+	formatter := &Formatter{output: output, timestamps: options.Timestamps}
+	if options.Template != "" {
+		parsed, err := template.New("record").Funcs(templateFuncs).Parse(options.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		formatter.template = parsed
+	}
+	return formatter, nil
+}
+
+// IsText : true when records are printed in the default human readable layout
+func (f *Formatter) IsText() bool {
+	return f.template == nil && f.output == OutputText
+}
+
+// FormatApplicationLog : render an application log record
+func (f *Formatter) FormatApplicationLog(logs models.VectorLogsStruct) (string, error) {
+	if f.template != nil {
+		text, err := f.execute(logs)
+		return f.withTimestamp(logs.Timestamp, text, err)
+	}
+
+	switch f.output {
+	case OutputJSON:
+		return marshalIndent(logs)
+	case OutputNDJSON:
+		return marshal(logs)
+	case OutputLogfmt:
+		return f.logfmtTimestamp(logs.Timestamp) + applicationLogfmt(logs), nil
+	case OutputRaw:
+		text, err := messageString(logs.Message)
+		return f.withTimestamp(logs.Timestamp, text, err)
+	default:
+		text, err := applicationText(logs)
+		return f.withTimestamp(logs.Timestamp, text, err)
+	}
+}
+
+// FormatAsgLog : render an ASG event, ASG events have no message so raw output uses the description
+func (f *Formatter) FormatAsgLog(logs models.AsgLogsStruct) (string, error) {
+	if f.template != nil {
+		text, err := f.execute(logs)
+		return f.withTimestamp(logs.Timestamp, text, err)
+	}
+
+	switch f.output {
+	case OutputNDJSON:
+		return marshal(logs)
+	case OutputLogfmt:
+		return f.logfmtTimestamp(logs.Timestamp) + asgLogfmt(logs), nil
+	case OutputRaw:
+		return f.withTimestamp(logs.Timestamp, logs.Description, nil)
+	default:
+		return marshalIndent(logs)
+	}
+}
+
+// FormatTimestamp : render a record time in one of the supported timestamps formats
+func FormatTimestamp(timestamp time.Time, format string) string {
+	switch format {
+	case TimestampEpochMillis:
+		return fmt.Sprint(timestamp.UnixMilli())
+	case TimestampRelative:
+		elapsed := time.Since(timestamp).Round(time.Second)
+		if elapsed < 0 {
+			return "in " + (-elapsed).String()
+		}
+		return elapsed.String() + " ago"
+	default:
+		return timestamp.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+func (f *Formatter) withTimestamp(timestamp time.Time, text string, err error) (string, error) {
+	if err != nil || f.timestamps == "" {
+		return text, err
+	}
+	return FormatTimestamp(timestamp, f.timestamps) + "\t" + text, nil
+}
+
+func (f *Formatter) logfmtTimestamp(timestamp time.Time) string {
+	if f.timestamps == "" {
+		return ""
+	}
+	var builder strings.Builder
+	writeLogfmtPair(&builder, "timestamp", FormatTimestamp(timestamp, f.timestamps))
+	builder.WriteByte(' ')
+	return builder.String()
+}
+
+func (f *Formatter) execute(data interface{}) (string, error) {
+	var buffer bytes.Buffer
+	if err := f.template.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buffer.String(), nil
+}
+
+func applicationText(logs models.VectorLogsStruct) (string, error) {
+	message, err := messageString(logs.Message)
+	if err != nil {
+		return "", err
+	}
+
+	ddtags, err := json.Marshal(logs.Ddtags)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode ddtags: %w", err)
+	}
+
+	if logs.Ddtags == nil || string(ddtags) == constants.EmptyJSON || string(ddtags) == "null" {
+		return fmt.Sprintf("%s\t%s\t%s", logs.Service, logs.Hostname, message), nil
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s", logs.Service, logs.Hostname, string(ddtags), message), nil
+}
+
+func applicationLogfmt(logs models.VectorLogsStruct) string {
+	var builder strings.Builder
+	writeLogfmtPair(&builder, "service_name", logs.Service)
+	writeLogfmtPair(&builder, "component_name", logs.ComponentName)
+	writeLogfmtPair(&builder, "env", logs.Env)
+	writeLogfmtPair(&builder, "hostname", logs.Hostname)
+	if tags, ok := logs.Ddtags.(map[string]string); ok {
+		keys := make([]string, 0, len(tags))
+		for key := range tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeLogfmtPair(&builder, "ddtags."+key, tags[key])
+		}
+	}
+	message, err := messageString(logs.Message)
+	if err != nil {
+		message = fmt.Sprint(logs.Message)
+	}
+	writeLogfmtPair(&builder, "message", message)
+	return builder.String()
+}
+
+func asgLogfmt(logs models.AsgLogsStruct) string {
+	var builder strings.Builder
+	writeLogfmtPair(&builder, "accountId", logs.AccountId)
+	writeLogfmtPair(&builder, "autoScalingGroupName", logs.AutoScalingGroupName)
+	writeLogfmtPair(&builder, "event", logs.Event)
+	writeLogfmtPair(&builder, "statusCode", logs.StatusCode)
+	writeLogfmtPair(&builder, "statusMessage", logs.StatusMessage)
+	writeLogfmtPair(&builder, "progress", logs.Progress)
+	writeLogfmtPair(&builder, "activityId", logs.ActivityId)
+	writeLogfmtPair(&builder, "requestId", logs.RequestId)
+	writeLogfmtPair(&builder, "ec2InstanceId", logs.EC2InstanceId)
+	writeLogfmtPair(&builder, "startTime", logs.StartTime)
+	writeLogfmtPair(&builder, "endTime", logs.EndTime)
+	writeLogfmtPair(&builder, "cause", logs.Cause)
+	writeLogfmtPair(&builder, "description", logs.Description)
+	writeLogfmtPair(&builder, "details", logs.Details)
+	return builder.String()
+}
+
+func writeLogfmtPair(builder *strings.Builder, key, value string) {
+	if builder.Len() > 0 {
+		builder.WriteByte(' ')
+	}
+	builder.WriteString(key)
+	builder.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\n\r") {
+		builder.WriteString(fmt.Sprintf("%q", value))
+	} else {
+		builder.WriteString(value)
+	}
+}
+
+func messageString(message interface{}) (string, error) {
+	if msg, ok := message.(string); ok {
+		return msg, nil
+	}
+	msg, err := json.Marshal(message)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode message: %w", err)
+	}
+	return string(msg), nil
+}
+
+func marshal(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode record: %w", err)
+	}
+	return string(data), nil
+}
+
+func marshalIndent(value interface{}) (string, error) {
+	data, err := json.MarshalIndent(value, "", " ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode record: %w", err)
+	}
+	return string(data), nil
+}
+
+func isSupported(value string, supportedValues []string) bool {
+	for _, supported := range supportedValues {
+		if supported == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package hostkeys
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ErrUnknownKey is returned when a host presents a key that is neither pinned nor known and trust-on-first-use is disabled
+var ErrUnknownKey = errors.New("host key is not known")
+
+// ChangedKeyError is returned when a host presents a key different from the pinned or known one
+type ChangedKeyError struct {
+	Host        string
+	Fingerprint string
+	Expected    []string
+	Source      string
+}
+
+func (e *ChangedKeyError) Error() string {
+	return fmt.Sprintf("host key of %s has changed: got %s, expected %s (from %s)", e.Host, e.Fingerprint, strings.Join(e.Expected, ", "), e.Source)
+}
+
+// Verifier checks host keys against pinned fingerprints and known_hosts files
+type Verifier struct {
+	// Fingerprints are the expected SHA256 fingerprints, when set no other source is consulted
+	Fingerprints []string
+	// KnownHostsFiles are read-only known_hosts files, such as ~/.ssh/known_hosts
+	KnownHostsFiles []string
+	// ManagedKnownHostsFile is the known_hosts file owned by livelogs, new keys are recorded here
+	ManagedKnownHostsFile string
+	// TrustOnFirstUse records the key of a host that is not known yet instead of rejecting it
+	TrustOnFirstUse bool
+	// OnTrust is called after a new host key is recorded
+	OnTrust func(host, fingerprint string)
+
+	mu sync.Mutex
+}
+
+// Callback : host key callback checking keys for the logical host, whatever IP it was dialed on
+func (v *Verifier) Callback(host string) ssh.HostKeyCallback {
+	return func(_ string, remote net.Addr, key ssh.PublicKey) error {
+		return v.verify(host, remote, key)
+	}
+}
+
+func (v *Verifier) verify(host string, remote net.Addr, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	if len(v.Fingerprints) > 0 {
+		for _, expected := range v.Fingerprints {
+			if normalizeFingerprint(expected) == fingerprint {
+				return nil
+			}
+		}
+		return &ChangedKeyError{Host: host, Fingerprint: fingerprint, Expected: v.Fingerprints, Source: "log search config"}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Known hosts files of the user are checked by host name, as ssh does
+	known, err := checkKnownHosts(existingFiles(v.KnownHostsFiles), host, host, remote, key)
+	if known || err != nil {
+		return err
+	}
+	// The hosts behind a name may each have a key of their own, so livelogs records and checks them per host and IP
+	address := remote.String()
+	known, err = checkKnownHosts(existingFiles([]string{v.ManagedKnownHostsFile}), host, address, remote, key)
+	if known || err != nil {
+		return err
+	}
+
+	if !v.TrustOnFirstUse || v.ManagedKnownHostsFile == "" {
+		return fmt.Errorf("%w: %s (%s) presented %s", ErrUnknownKey, host, address, fingerprint)
+	}
+	if err := appendKnownHost(v.ManagedKnownHostsFile, []string{host, address}, key); err != nil {
+		return fmt.Errorf("failed to record host key of %s: %w", host, err)
+	}
+	if v.OnTrust != nil {
+		v.OnTrust(host+" ("+address+")", fingerprint)
+	}
+	return nil
+}
+
+// checkKnownHosts : whether the files hold the key for the address, a ChangedKeyError when they hold another one
+func checkKnownHosts(files []string, host, address string, remote net.Addr, key ssh.PublicKey) (bool, error) {
+	if len(files) == 0 {
+		return false, nil
+	}
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return false, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+	err = callback(address, remote, key)
+	var keyError *knownhosts.KeyError
+	if err == nil || !errors.As(err, &keyError) {
+		return err == nil, err
+	}
+	if len(keyError.Want) == 0 {
+		return false, nil
+	}
+	expected := make([]string, 0, len(keyError.Want))
+	sources := make([]string, 0, len(keyError.Want))
+	for _, known := range keyError.Want {
+		expected = append(expected, ssh.FingerprintSHA256(known.Key))
+		sources = append(sources, fmt.Sprintf("%s:%d", known.Filename, known.Line))
+	}
+	if address != host {
+		host += " (" + address + ")"
+	}
+	return false, &ChangedKeyError{Host: host, Fingerprint: ssh.FingerprintSHA256(key), Expected: expected, Source: strings.Join(sources, ", ")}
+}
+
+func appendKnownHost(path string, addresses []string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	patterns := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		patterns = append(patterns, knownhosts.Normalize(address))
+	}
+	if _, err := file.WriteString(knownhosts.Line(patterns, key) + "\n"); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func existingFiles(paths []string) []string {
+	var files []string
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// normalizeFingerprint : accept fingerprints with or without the SHA256: prefix and base64 padding
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimSpace(fingerprint)
+	fingerprint = strings.TrimPrefix(fingerprint, "SHA256:")
+	return "SHA256:" + strings.TrimRight(fingerprint, "=")
+}
@@ -0,0 +1,752 @@
+package livelogs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/encryption"
+	"github.com/dream11/livelogs/pkg/formatter"
+	"github.com/dream11/livelogs/pkg/hostkeys"
+	"github.com/dream11/livelogs/pkg/logger"
+	"github.com/dream11/livelogs/pkg/protocol"
+	"github.com/dream11/livelogs/pkg/resolver"
+	"github.com/dream11/livelogs/util"
+	"golang.org/x/crypto/ssh"
+)
+
+// centralAgentStream is the log source of the central livelogs agent, reached over SSH. It keeps track of the offsets
+// the agent reports, so that a dropped connection can be re-established where the previous one stopped. The agent
+// filters and orders the records itself, the lines of an agent that only speaks text are delivered as text records.
+type centralAgentStream struct {
+	logSearchConfig models.LogSearchConfig
+	query           *Query
+	sshConfig       *ssh.ClientConfig
+	encodedFilter   string
+	stats           *sessionStats
+	logger          Logger
+	orchestrator    *util.Orchestrator
+	resolver        *resolver.Resolver
+	// trustOnFirstUse records unknown host keys of the agent instead of rejecting them
+	trustOnFirstUse bool
+	// encodedConfig is the log search config as the central livelogs agent reads it from stdin
+	encodedConfig []byte
+	records       chan *models.LogRecord
+	err           error
+
+	// protocolVersion is the framed protocol version asked for, 0 once the agent is known to only speak text
+	protocolVersion int
+	// framed is set by the handshake of the current session when the agent answers with frames
+	framed         bool
+	helloReceived  bool
+	fallbackToText bool
+	stderr         *pendingWriter
+	// received is set once the current session delivers output, a session that ends after it is not a failed one
+	received atomic.Bool
+
+	mu          sync.Mutex
+	nextOffsets map[int32]int64
+	hostKeyErr  error
+}
+
+// pendingWriter holds output back until it is released, so that errors of an agent that does not speak the
+// protocol are not shown when the session is retried in text mode
+type pendingWriter struct {
+	mu       sync.Mutex
+	out      io.Writer
+	buffer   bytes.Buffer
+	released bool
+}
+
+func (w *pendingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.released {
+		return w.out.Write(p)
+	}
+	return w.buffer.Write(p)
+}
+
+func (w *pendingWriter) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.released {
+		return
+	}
+	w.released = true
+	_, _ = w.out.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+}
+
+func (w *pendingWriter) discard() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	held := w.buffer.String()
+	w.buffer.Reset()
+	return held
+}
+
+// errConnectionLost is returned when the stream ends without the remote command reporting an exit status
+var errConnectionLost = errors.New("connection to central livelogs agent lost")
+
+// errProtocolMismatch is returned when the agent answers the handshake with a protocol version this client does not understand
+var errProtocolMismatch = errors.New("unsupported protocol version")
+
+func newCentralAgentSource(query *Query, logSearchConfig models.LogSearchConfig, encodedFilter string, stats *sessionStats, client *Client) *centralAgentStream {
+	stream := &centralAgentStream{
+		logSearchConfig: logSearchConfig,
+		query:           query,
+		encodedFilter:   encodedFilter,
+		stats:           stats,
+		logger:          client.options.Logger,
+		orchestrator:    client.orchestrator,
+		resolver:        client.resolver,
+		trustOnFirstUse: client.options.TrustHostKeysOnFirstUse,
+		nextOffsets:     map[int32]int64{},
+	}
+	// Piping through a linux operation works on the text output only
+	if query.LinuxOperation == "" {
+		stream.protocolVersion = protocol.Version
+	}
+	for partition, offset := range query.ResumeOffsets {
+		stream.nextOffsets[partition] = offset
+	}
+	return stream
+}
+
+// IsOrdered : the central livelogs agent orders the records itself when asked to
+func (s *centralAgentStream) IsOrdered() bool {
+	return true
+}
+
+// Records : connect to the central livelogs agent and stream its records until its command exits or the context is done
+func (s *centralAgentStream) Records(ctx context.Context) (<-chan *models.LogRecord, error) {
+	log.Debug("Reading logs from central livelogs agent")
+
+	encodedConfig, err := json.Marshal(s.logSearchConfig)
+	if err != nil {
+		return nil, errorf(ErrConfig, "error in marshalling log search config: %w", err)
+	}
+	s.encodedConfig = append(encodedConfig, '\n')
+
+	decryptedPem, err := encryption.Decrypt(s.logSearchConfig.LiveLogAgentSshPemKey, s.logSearchConfig.LiveLogAgentSecretKey, s.logSearchConfig.LiveLogAgentSecretIv)
+	if err != nil {
+		return nil, errorf(ErrAuth, "failed to decrypt ssh key of central livelogs agent: %w", err)
+	}
+	logger.RegisterSecret(decryptedPem)
+
+	pemAuth, err := getPemAuth(decryptedPem)
+	if err != nil {
+		return nil, err
+	}
+	s.sshConfig = &ssh.ClientConfig{
+		User:    s.logSearchConfig.LiveLogAgentSshUser,
+		Auth:    []ssh.AuthMethod{pemAuth},
+		Timeout: constants.CentralLiveLogAgentSshTimeout,
+	}
+	s.sshConfig.HostKeyCallback = s.hostKeyCallback(s.hostKeyVerifier())
+
+	s.records = make(chan *models.LogRecord, constants.RecordChannelSize)
+	go func() {
+		defer close(s.records)
+		s.err = s.stream(ctx)
+	}()
+	return s.records, nil
+}
+
+// Err : error that made the stream give up the central livelogs agent
+func (s *centralAgentStream) Err() error {
+	return s.err
+}
+
+// stream : run the command on the central livelogs agent, reconnecting whenever the connection is lost
+func (s *centralAgentStream) stream(ctx context.Context) error {
+	s.logger.Info("Connecting to central livelogs agent...")
+	if s.protocolVersion == 0 {
+		s.warnLegacyFlags()
+	}
+	isFirstSession := true
+	// Sessions that fail before delivering anything, on a host that can be dialed, are retried with backoff too
+	backoff := constants.CentralLiveLogAgentInitialBackoff
+	failedSessions := 0
+	for {
+		client, err := s.connect(ctx)
+		if err != nil || client == nil {
+			return err
+		}
+		if isFirstSession {
+			go func() {
+				s.orchestrator.UserLogFunc(&models.LogsCommandArgs{
+					Env:           s.query.Env,
+					Org:           s.query.Org,
+					Account:       s.query.Account,
+					ServiceName:   s.query.ServiceName,
+					ComponentName: s.query.ComponentName,
+					StartTime:     s.query.StartTime,
+					EndTime:       s.query.EndTime,
+					Since:         s.query.Since,
+				}, s.logSearchConfig.Tenant)
+			}()
+			isFirstSession = false
+		}
+
+		err = s.run(ctx, client)
+		_ = client.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var exitError *ssh.ExitError
+		switch {
+		case errors.Is(err, errProtocolMismatch):
+			s.logger.Warn("Central livelogs agent does not support the framed protocol, falling back to text output")
+			s.protocolVersion = 0
+			s.warnLegacyFlags()
+		case err == nil:
+			return nil
+		case errors.As(err, &exitError):
+			return errorf(ErrAgent, "command execution on central livelogs agent failed: %w", err)
+		case s.received.Load():
+			log.Debug("Central livelogs agent session ended: " + err.Error())
+			s.logger.Warn("Connection to central livelogs agent lost, reconnecting…")
+			if s.protocolVersion == 0 {
+				s.logger.Warn("Text output can not be resumed, records may be repeated or missed")
+			}
+			backoff, failedSessions = constants.CentralLiveLogAgentInitialBackoff, 0
+		default:
+			failedSessions++
+			log.Debug(fmt.Sprintf("Central livelogs agent session %d failed: %v", failedSessions, err))
+			if failedSessions >= constants.CentralLiveLogAgentMaxConnectAttempts {
+				return errorf(ErrAgent, "central livelogs agent session failed %d times in a row: %w", failedSessions, err)
+			}
+			s.logger.Warn(fmt.Sprintf("Central livelogs agent session failed, retrying in %v…", backoff))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff)
+		}
+	}
+}
+
+// nextBackoff : double the backoff between attempts, up to the maximum
+func nextBackoff(backoff time.Duration) time.Duration {
+	return min(backoff*2, constants.CentralLiveLogAgentMaxBackoff)
+}
+
+// connect : dial every resolved IP of the central livelogs agent host, retrying with backoff, nil once the context is done
+func (s *centralAgentStream) connect(ctx context.Context) (*ssh.Client, error) {
+	backoff := constants.CentralLiveLogAgentInitialBackoff
+	var dnsErr error
+	for attempt := 1; attempt <= constants.CentralLiveLogAgentMaxConnectAttempts; attempt++ {
+		ips, err := util.GetIpsFromHost(s.resolver, s.logSearchConfig.LiveLogAgentHost)
+		dnsErr = err
+		if err == nil && len(ips) == 0 {
+			dnsErr = fmt.Errorf("no addresses found for %s", s.logSearchConfig.LiveLogAgentHost)
+		}
+		if dnsErr != nil {
+			log.Debug(fmt.Sprintf("Failed to resolve central livelogs agent host: %v", dnsErr))
+			s.logger.Warn("Unable to resolve DNS of central livelogs agent host")
+		}
+		rand.Shuffle(len(ips), func(i, j int) { ips[i], ips[j] = ips[j], ips[i] })
+
+		for _, ip := range ips {
+			remoteAddr := net.JoinHostPort(ip, strconv.Itoa(s.logSearchConfig.LiveLogAgentSshPort))
+			log.Debug("Connecting to central livelogs agent IP: " + ip)
+			s.hostKeyErr = nil
+			client, err := ssh.Dial("tcp", remoteAddr, s.sshConfig)
+			if err == nil {
+				return client, nil
+			}
+			if s.hostKeyErr != nil {
+				// A host key that fails verification is never retried, it may be a spoofed agent
+				log.Debug("Host key verification failed: " + s.hostKeyErr.Error())
+				return nil, errorf(ErrHostKey, "host key verification of central livelogs agent failed: %w", s.hostKeyErr)
+			}
+			log.Debug(fmt.Sprintf("Failed to connect to central livelogs agent %s: %v", remoteAddr, err))
+		}
+
+		if attempt == constants.CentralLiveLogAgentMaxConnectAttempts {
+			break
+		}
+		s.logger.Warn(fmt.Sprintf("Unable to reach central livelogs agent, retrying in %v…", backoff))
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(backoff):
+		}
+		backoff = nextBackoff(backoff)
+	}
+
+	if dnsErr != nil {
+		return nil, errorf(ErrDns, "failed to resolve central livelogs agent host after %d attempts: %w", constants.CentralLiveLogAgentMaxConnectAttempts, dnsErr)
+	}
+	return nil, errorf(ErrAgent, "failed to connect to central livelogs agent after %d attempts", constants.CentralLiveLogAgentMaxConnectAttempts)
+}
+
+// hostKeyCallback : verify the key against the agent host name rather than the IP it was dialed on, keeping the
+// verification error since the ssh package only reports it as text
+func (s *centralAgentStream) hostKeyCallback(verifier *hostkeys.Verifier) ssh.HostKeyCallback {
+	host := net.JoinHostPort(s.logSearchConfig.LiveLogAgentHost, strconv.Itoa(s.logSearchConfig.LiveLogAgentSshPort))
+	callback := verifier.Callback(host)
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		s.hostKeyErr = err
+		return err
+	}
+}
+
+// hostKeyVerifier : verifier of the host keys of the agent, unknown keys are only trusted when the client opted in
+func (s *centralAgentStream) hostKeyVerifier() *hostkeys.Verifier {
+	verifier := &hostkeys.Verifier{
+		Fingerprints:    s.logSearchConfig.LiveLogAgentHostKeys,
+		TrustOnFirstUse: s.trustOnFirstUse,
+		OnTrust: func(host, fingerprint string) {
+			s.logger.Warn(fmt.Sprintf("Trusting host key %s of %s on first use", fingerprint, host))
+		},
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		verifier.KnownHostsFiles = []string{filepath.Join(homeDir, ".ssh", constants.KnownHostsFileName)}
+	}
+	if livelogsDir, err := util.GetLivelogsDir(); err == nil {
+		verifier.ManagedKnownHostsFile = filepath.Join(livelogsDir, constants.KnownHostsFileName)
+	} else {
+		log.Debug("Unable to access livelogs directory, host keys will not be recorded: " + err.Error())
+	}
+	return verifier
+}
+
+// keepAlive : close the client when the central livelogs agent stops answering keepalives, so that a dead
+// connection is detected even when no logs are flowing
+func keepAlive(client *ssh.Client, done <-chan struct{}) {
+	ticker := time.NewTicker(constants.CentralLiveLogAgentKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case <-done:
+			return
+		case err := <-reply:
+			if err != nil {
+				log.Debug("Keepalive to central livelogs agent failed: " + err.Error())
+				_ = client.Close()
+				return
+			}
+		case <-time.After(constants.CentralLiveLogAgentKeepAliveTimeout):
+			log.Debug("Keepalive to central livelogs agent timed out")
+			_ = client.Close()
+			return
+		}
+	}
+}
+
+// run : execute the livelogs command on the central livelogs agent and print its output until it exits
+func (s *centralAgentStream) run(ctx context.Context, client *ssh.Client) (err error) {
+	s.framed, s.helloReceived, s.fallbackToText = false, false, false
+	s.received.Store(false)
+	s.stderr = &pendingWriter{out: os.Stderr, released: s.protocolVersion == 0}
+	defer func() {
+		var exitError *ssh.ExitError
+		if s.fallbackToText || (s.protocolVersion > 0 && !s.helloReceived && errors.As(err, &exitError)) {
+			log.Debug(fmt.Sprintf("Central livelogs agent does not speak protocol v%d: %v %s", s.protocolVersion, err, s.stderr.discard()))
+			err = errProtocolMismatch
+			return
+		}
+		s.stderr.release()
+	}()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	stdoutPipe, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to fetch data: %w", err)
+	}
+	session.Stderr = s.stderr
+	stdinPipe, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open stdin: %w", err)
+	}
+
+	liveLogsUser, err := os.Hostname()
+	if err != nil {
+		log.Debug("Error in fetching hostname: " + err.Error())
+		liveLogsUser = constants.EnvLivelogsUser
+	}
+	// The mode travels as an environment variable, which agents that predate it ignore
+	envVars := fmt.Sprintf("%s=\"%s\" %s=%s", constants.EnvLivelogsUser, liveLogsUser, constants.EnvLivelogsMode, constants.ModeCentral)
+	agentCommand := s.legacyCommand()
+	if s.protocolVersion > 0 {
+		agentCommand = s.command(s.resumeOffsets(), s.protocolVersion)
+	}
+	command := fmt.Sprintf("env %s %s", envVars, agentCommand)
+	log.Debug(fmt.Sprintf("User: [%s] is executing command: [%s] on central live log agent", liveLogsUser, command))
+	if err := session.Start(command); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+	if _, err := stdinPipe.Write(s.encodedConfig); err != nil {
+		return fmt.Errorf("failed to send log search config: %w", err)
+	}
+	_ = stdinPipe.Close()
+
+	keepAliveDone := make(chan struct{})
+	defer close(keepAliveDone)
+	go keepAlive(client, keepAliveDone)
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		s.readOutput(stdoutPipe)
+	}()
+
+	sessionDone := make(chan error, 1)
+	go func() {
+		sessionDone <- session.Wait()
+	}()
+
+	select {
+	case <-readerDone:
+		if s.fallbackToText {
+			return errProtocolMismatch
+		}
+		err := <-sessionDone
+		var exitMissingError *ssh.ExitMissingError
+		if errors.As(err, &exitMissingError) || errors.Is(err, io.EOF) {
+			return errConnectionLost
+		}
+		return err
+	case <-ctx.Done():
+		// Ask the central livelogs agent to shut down gracefully so that it closes its Kafka consumers
+		log.Debug("Sending interrupt signal to central livelogs agent")
+		if err := session.Signal(ssh.SIGINT); err != nil {
+			log.Debug("Failed to signal central livelogs agent: " + err.Error())
+		}
+		select {
+		case <-sessionDone:
+		case <-time.After(constants.ShutdownGracePeriod):
+			log.Debug("Central livelogs agent did not stop in time, closing the connection")
+			_ = session.Close()
+			_ = client.Close()
+		}
+		// The records channel is closed once run returns, the output must be fully read by then
+		<-readerDone
+		return ctx.Err()
+	}
+}
+
+func (s *centralAgentStream) readOutput(stdout io.Reader) {
+	received := protocol.NewCountingReader(stdout)
+	reader := bufio.NewReader(received)
+	var decompressed *protocol.CountingReader
+	defer func() {
+		if decompressed != nil {
+			reportCompression(received.Count(), decompressed.Count())
+		}
+	}()
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// A compressed stream is never ended by the agent, it stops at the last flush
+			if err != io.EOF && !(decompressed != nil && errors.Is(err, io.ErrUnexpectedEOF)) {
+				log.Debug("Error reading from central livelogs agent: " + err.Error())
+			}
+			return
+		}
+		s.received.Store(true)
+		if s.protocolVersion > 0 {
+			frame, isFrame, err := protocol.Parse(line)
+			if err != nil {
+				log.Debug("Error decoding frame from central livelogs agent: " + err.Error())
+				continue
+			}
+			if isFrame {
+				if !s.handleFrame(frame) {
+					return
+				}
+				if frame.Type == protocol.FrameHello && s.framed && frame.Compression != "" {
+					decompressor, err := protocol.NewDecompressor(frame.Compression, reader)
+					if err != nil {
+						log.Debug("Failed to decompress output of central livelogs agent: " + err.Error())
+						s.fallbackToText = true
+						return
+					}
+					if closer, ok := decompressor.(io.Closer); ok {
+						defer closer.Close()
+					}
+					decompressed = protocol.NewCountingReader(decompressor)
+					reader = bufio.NewReader(decompressed)
+				}
+				continue
+			}
+		}
+		if offsets, isCheckpoint := parseOffsetCheckpoint(line); isCheckpoint {
+			s.updateOffsets(offsets)
+			continue
+		}
+		s.stats.markUncounted()
+		s.records <- textRecord(line)
+	}
+}
+
+// textRecord : record of a line of an agent that only speaks text, its message is the line without its line break
+func textRecord(line string) *models.LogRecord {
+	receivedAt := time.Now()
+	return &models.LogRecord{
+		Timestamp:   receivedAt,
+		Application: &models.VectorLogsStruct{Message: strings.TrimRight(line, "\r\n"), Timestamp: receivedAt},
+		Text:        line,
+	}
+}
+
+func reportCompression(received, decompressed int64) {
+	if received == 0 {
+		return
+	}
+	log.Debug(fmt.Sprintf("Compression: received %d bytes for %d bytes of output, ratio %.1fx", received, decompressed, float64(decompressed)/float64(received)))
+}
+
+// handleFrame : act on a frame of the central livelogs agent, false when the session has to be given up
+func (s *centralAgentStream) handleFrame(frame *protocol.Frame) bool {
+	switch frame.Type {
+	case protocol.FrameHello:
+		s.helloReceived = true
+		if frame.Version != 0 && !protocol.IsSupported(frame.Version) {
+			s.fallbackToText = true
+			return false
+		}
+		s.framed = frame.Version != 0
+		s.stderr.release()
+		log.Debug(fmt.Sprintf("Central livelogs agent %s answered with protocol v%d, compression: %q", frame.Agent, frame.Version, frame.Compression))
+	case protocol.FrameRecord:
+		if !s.framed || frame.Record == nil {
+			return true
+		}
+		s.stats.recordsRead.Add(1)
+		s.records <- frame.Record
+	case protocol.FrameOffsets:
+		s.updateOffsets(frame.Offsets)
+	default:
+		log.Debug("Ignoring unknown frame from central livelogs agent: " + frame.Type)
+	}
+	return true
+}
+
+func (s *centralAgentStream) updateOffsets(offsets map[int32]int64) {
+	s.mu.Lock()
+	for partition, offset := range offsets {
+		s.nextOffsets[partition] = offset + 1
+	}
+	s.mu.Unlock()
+	s.stats.recordOffsets(offsets)
+}
+
+// resumeOffsets : offsets to continue from, the ones passed at start-up overridden by what the agent reported since
+func (s *centralAgentStream) resumeOffsets() map[int32]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets := make(map[int32]int64, len(s.nextOffsets))
+	for partition, offset := range s.nextOffsets {
+		offsets[partition] = offset
+	}
+	return offsets
+}
+
+func getPemAuth(key string) (ssh.AuthMethod, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(key))
+	if err != nil {
+		return nil, errorf(ErrAuth, "unable to parse ssh key of central livelogs agent: %w", err)
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// command : logs command of the query as the central livelogs agent runs it with the framed protocol. The local session
+// decides when to stop and can be extended, so the agent only enforces the server limit.
+func (s *centralAgentStream) command(resumeOffsets map[int32]int64, protocolVersion int) string {
+	query := s.query
+	reorderWindow := ""
+	if query.Ordered {
+		reorderWindow = query.ReorderWindow.String()
+	}
+	flags := []struct {
+		name  string
+		value string
+	}{
+		{constants.ArgumentAccount, query.Account},
+		{constants.AsgName, query.AsgName},
+		{constants.ArgumentCloudProvider, query.CloudProvider},
+		{constants.ArgumentComponentName, query.ComponentName},
+		{constants.ArgumentComponentType, query.ComponentType},
+		{constants.ArgumentEndTime, query.EndTime},
+		{constants.ArgumentEnv, query.Env},
+		{constants.ArgumentLevel, query.Level},
+		{constants.ArgumentLevelMapping, query.LevelMapping},
+		// Agents released before the rename only know the alias, newer ones accept both
+		{constants.ArgumentNoFollowAlias, strconv.FormatBool(query.NoFollow)},
+		{constants.ArgumentOrdered, strconv.FormatBool(query.Ordered)},
+		{constants.ArgumentOrg, query.Org},
+		{constants.ArgumentOutput, query.Text.Output},
+		{constants.ArgumentReorderWindow, reorderWindow},
+		{constants.ArgumentServiceName, query.ServiceName},
+		{constants.ArgumentShowTags, query.ShowTags},
+		{constants.ArgumentSince, query.Since},
+		{constants.ArgumentStartTime, query.StartTime},
+		{constants.ArgumentTemplate, query.Text.Template},
+		{constants.ArgumentTimestamps, query.Text.Timestamps},
+		{constants.ArgumentTimezone, query.Timezone},
+		{constants.ArgumentVerbose, strconv.FormatBool(log.IsDebugModeEnabled())},
+	}
+	command := constants.CentralLiveLogAgentName + " logs "
+	for _, flag := range flags {
+		if flag.value != "" && flag.value != "false" {
+			command += "--" + flag.name + "=" + util.ShellQuote(flag.value) + " "
+		}
+	}
+	command += "--" + constants.ArgumentTimeout + "=0"
+
+	// The log search config holds secrets, it is written to the stdin of the session instead of the command line
+	command += " --" + constants.LogSearchConfig + "=" + constants.LogSearchConfigFromStdin
+
+	// The filter travels as encoded data so that it is never interpreted by the shell of the central livelogs agent
+	if s.encodedFilter != "" {
+		command += " --" + constants.EncodedFilter + "=" + util.ShellQuote(s.encodedFilter)
+	}
+
+	// Offsets are streamed back so that the local client can resume after a reconnect. An agent that accepts the
+	// protocol accepts them too, one that does not fails the handshake and is asked for the legacy command instead.
+	command += " --" + constants.EmitOffsets
+	command += " --" + constants.Protocol + "=" + strconv.Itoa(protocolVersion)
+	if query.Compress {
+		command += " --" + constants.Compression + "=" + strings.Join(protocol.SupportedCompressions, ",")
+	}
+	if len(resumeOffsets) > 0 {
+		// A map of integers always encodes
+		resumeOffsetsJson, _ := json.Marshal(resumeOffsets)
+		command += " --" + constants.ResumeOffsets + "=" + util.ShellQuote(string(resumeOffsetsJson))
+	}
+	log.Debug("Central live log agent command: " + command)
+	return command
+}
+
+// legacyCommand : logs command of the query with only the flags of agents that predate the framed protocol, which
+// read times in IST. Text output, as asked for by a linux operation, is always read this way since an agent that
+// answers it can not be told apart from an old one.
+func (s *centralAgentStream) legacyCommand() string {
+	query := s.query
+	flags := []struct {
+		name  string
+		value string
+	}{
+		{constants.ArgumentAccount, query.Account},
+		{constants.AsgName, query.AsgName},
+		{constants.ArgumentCloudProvider, query.CloudProvider},
+		{constants.ArgumentComponentName, query.ComponentName},
+		{constants.ArgumentComponentType, query.ComponentType},
+		{constants.ArgumentEndTime, legacyTime(query.EndTime, query.Timezone)},
+		{constants.ArgumentEnv, query.Env},
+		{constants.ArgumentOrg, query.Org},
+		{constants.ArgumentServiceName, query.ServiceName},
+		{constants.ArgumentShowTags, query.ShowTags},
+		{constants.ArgumentSince, query.Since},
+		{constants.ArgumentStartTime, legacyTime(query.StartTime, query.Timezone)},
+		{constants.ArgumentVerbose, strconv.FormatBool(log.IsDebugModeEnabled())},
+	}
+	command := constants.CentralLiveLogAgentName + " logs "
+	for _, flag := range flags {
+		if flag.value != "" && flag.value != "false" {
+			command += "--" + flag.name + "=" + util.ShellQuote(flag.value) + " "
+		}
+	}
+	// The log search config holds secrets, it never goes on the command line where ps would show it
+	command += "--" + constants.LogSearchConfig + "=" + constants.LogSearchConfigFromStdin
+
+	if len(query.LinuxOperation) > 0 {
+		command += " | " + query.LinuxOperation
+	}
+	return command
+}
+
+// legacyTime : time expression as agents that predate the framed protocol parse it, in IST
+func legacyTime(expression, timezone string) string {
+	if expression == "" {
+		return ""
+	}
+	resolved, err := util.ResolveTime(expression, timezone)
+	if err != nil {
+		return expression
+	}
+	location, err := util.LoadTimezone(constants.DefaultTimezone)
+	if err != nil {
+		return expression
+	}
+	return resolved.In(location).Format("2006-01-02 15:04:05")
+}
+
+// warnLegacyFlags : name the options of the query that the text output of the agent can not apply
+func (s *centralAgentStream) warnLegacyFlags() {
+	query := s.query
+	var ignored []string
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{constants.ArgumentFilter, s.encodedFilter != ""},
+		{constants.ArgumentLevel, query.Level != ""},
+		{constants.ArgumentLevelMapping, query.LevelMapping != ""},
+		{constants.ArgumentNoFollow, query.NoFollow && query.EndTime == ""},
+		{constants.ArgumentOrdered, query.Ordered},
+		{constants.ArgumentOutput, query.Text.Output != "" && query.Text.Output != formatter.OutputText},
+		{constants.ArgumentTemplate, query.Text.Template != ""},
+		{constants.ArgumentTimestamps, query.Text.Timestamps != ""},
+	} {
+		if option.set {
+			ignored = append(ignored, "--"+option.name)
+		}
+	}
+	if len(ignored) > 0 {
+		s.logger.Warn("Text output of the central livelogs agent ignores " + strings.Join(ignored, ", "))
+	}
+}
+
+// parseOffsetCheckpoint : offsets carried by a checkpoint line of the central livelogs agent
+func parseOffsetCheckpoint(line string) (map[int32]int64, bool) {
+	data, found := strings.CutPrefix(strings.TrimRight(line, "\r\n"), constants.OffsetCheckpointPrefix)
+	if !found {
+		return nil, false
+	}
+	offsets := map[int32]int64{}
+	if err := json.Unmarshal([]byte(data), &offsets); err != nil {
+		log.Debug("Invalid offsets checkpoint from central livelogs agent: " + err.Error())
+	}
+	return offsets, true
+}
@@ -0,0 +1,135 @@
+package livelogs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+)
+
+// captureSource reads records captured one per line, as printed by --output ndjson, from a file or stdin. Lines that
+// are not JSON are read as plain text messages, so the output of other tools can be filtered as well.
+type captureSource struct {
+	name  string
+	open  func() (io.ReadCloser, error)
+	stats *sessionStats
+	err   error
+}
+
+func newFileSource(path string, stats *sessionStats) *captureSource {
+	return &captureSource{
+		name:  path,
+		open:  func() (io.ReadCloser, error) { return os.Open(path) },
+		stats: stats,
+	}
+}
+
+func newStdinSource(stats *sessionStats) *captureSource {
+	return &captureSource{
+		name:  constants.SourceStdin,
+		open:  func() (io.ReadCloser, error) { return io.NopCloser(os.Stdin), nil },
+		stats: stats,
+	}
+}
+
+// Records : records of every line until the end of the capture
+func (c *captureSource) Records(ctx context.Context) (<-chan *models.LogRecord, error) {
+	reader, err := c.open()
+	if err != nil {
+		return nil, errorf(ErrSource, "failed to read capture: %w", err)
+	}
+	log.Debug("Reading logs from " + c.name)
+
+	records := make(chan *models.LogRecord, constants.RecordChannelSize)
+	go func() {
+		defer close(records)
+		defer reader.Close()
+
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), constants.MaxCapturedLineSize)
+		var lineNumber int64
+		for scanner.Scan() {
+			lineNumber++
+			record := decodeCapturedLine(scanner.Text(), time.Now())
+			if record == nil {
+				continue
+			}
+			// The line number breaks ties between records of the same time when they are ordered
+			record.Offset = lineNumber
+			c.stats.recordsRead.Add(1)
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			c.err = errorf(ErrSource, "failed to read %s: %w", c.name, err)
+		}
+	}()
+	return records, nil
+}
+
+// Err : error reading the capture, the records read up to it are delivered
+func (c *captureSource) Err() error {
+	return c.err
+}
+
+// decodeCapturedLine : record of a captured line, either a JSON log record, application log or ASG event, or else a
+// plain text message received at receivedAt. Nil for blank lines.
+func decodeCapturedLine(line string, receivedAt time.Time) *models.LogRecord {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if strings.HasPrefix(trimmed, "{") && json.Unmarshal([]byte(trimmed), &fields) == nil {
+		if record := decodeCapturedJson([]byte(trimmed), fields, receivedAt); record != nil {
+			if record.Timestamp.IsZero() {
+				record.Timestamp = receivedAt
+			}
+			return record
+		}
+	}
+
+	return &models.LogRecord{
+		Timestamp:   receivedAt,
+		Application: &models.VectorLogsStruct{Message: line, Timestamp: receivedAt},
+	}
+}
+
+func decodeCapturedJson(data []byte, fields map[string]json.RawMessage, receivedAt time.Time) *models.LogRecord {
+	_, isApplicationRecord := fields["application"]
+	_, isAsgRecord := fields["asg"]
+	if isApplicationRecord || isAsgRecord {
+		var record models.LogRecord
+		if json.Unmarshal(data, &record) != nil || (record.Application == nil && record.Asg == nil) {
+			return nil
+		}
+		return &record
+	}
+
+	if _, isAsgEvent := fields["autoScalingGroupName"]; isAsgEvent {
+		var asgLogs models.AsgLogsStruct
+		if json.Unmarshal(data, &asgLogs) != nil {
+			return nil
+		}
+		return &models.LogRecord{Timestamp: asgLogs.Timestamp, Asg: &asgLogs}
+	}
+
+	var applicationLogs models.VectorLogsStruct
+	if json.Unmarshal(data, &applicationLogs) != nil {
+		return nil
+	}
+	if applicationLogs.Timestamp.IsZero() {
+		applicationLogs.Timestamp = receivedAt
+	}
+	return &models.LogRecord{Timestamp: applicationLogs.Timestamp, Application: &applicationLogs}
+}
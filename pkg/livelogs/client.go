@@ -0,0 +1,468 @@
+// Package livelogs streams the logs of components from Go code, the way the livelogs logs command does
+package livelogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/formatter"
+	"github.com/dream11/livelogs/pkg/logger"
+	"github.com/dream11/livelogs/pkg/recording"
+	"github.com/dream11/livelogs/pkg/resolver"
+	"github.com/dream11/livelogs/util"
+)
+
+var log logger.Logger
+
+// Record is a log record selected by a query
+type Record = *models.LogRecord
+
+// Options configure a client
+type Options struct {
+	// Mode is local, central or auto, it chooses the source of queries that do not name one (Default is
+	// LIVELOGS_MODE, else auto which detects cloud machines)
+	Mode string
+	// DnsServer resolves the livelogs hosts as host[:port] instead of the system resolver
+	DnsServer string
+	// OrchestratorUrl and CABundle locate the livelogs orchestrator (Default is LIVELOGS_ORCHESTRATOR_URL and
+	// LIVELOGS_CA_BUNDLE, else the ones of ~/.livelogs/config.yaml)
+	OrchestratorUrl string
+	CABundle        string
+	// Logger receives the messages meant for the person running the client, such as the time window of a query and
+	// reconnects to the central livelogs agent (Default discards them)
+	Logger Logger
+	// TrustHostKeysOnFirstUse records the host key of a central livelogs agent that is neither in the log search config
+	// nor in a known hosts file, instead of rejecting it
+	TrustHostKeysOnFirstUse bool
+}
+
+// Logger receives the progress messages and warnings of a client
+type Logger interface {
+	Info(message string)
+	Warn(message string)
+}
+
+// discardLogger is the Logger of clients that are not given one
+type discardLogger struct{}
+
+func (discardLogger) Info(string) {}
+
+func (discardLogger) Warn(string) {}
+
+// Query selects the logs to stream, its fields are the flags of the logs command
+type Query struct {
+	Env           string
+	Org           string
+	Account       string
+	CloudProvider string
+	ServiceName   string
+	ComponentName string
+	// ComponentType is application or asg (Default is application)
+	ComponentType string
+	AsgName       string
+
+	// Since is a duration such as 10m, StartTime and EndTime are time expressions read in Timezone
+	Since     string
+	StartTime string
+	EndTime   string
+	Timezone  string
+	// NoFollow ends the stream once the logs that exist when it starts are read, implied by EndTime
+	NoFollow bool
+
+	// Ordered delivers the records in timestamp order, within ReorderWindow when following new logs
+	Ordered       bool
+	ReorderWindow time.Duration
+	// ShowTags is a comma-separated list of the ddtags kept on the records, all of them when empty
+	ShowTags     string
+	Level        string
+	LevelMapping string
+	Filter       string
+	// EncodedFilter is a filter encoded by a local livelogs client, used when Filter is empty
+	EncodedFilter string
+
+	// Source is where the records are read from
+	Source Source
+	// Config is used instead of fetching the log search config from the livelogs orchestrator
+	Config *models.LogSearchConfig
+	// ResumeOffsets are the offsets partitions are read from instead of the time window
+	ResumeOffsets map[int32]int64
+	// Record is a file the Kafka messages are saved to as they are read, to be replayed
+	Record string
+	// Compress asks the central livelogs agent to compress what it sends
+	Compress bool
+	// Text renders the lines of a central livelogs agent that only sends text
+	Text formatter.Options
+	// LinuxOperation is a shell pipeline run on the text output of the central livelogs agent, deprecated for Filter
+	LinuxOperation string
+}
+
+// isBounded : true when the stream ends once the existing logs are read instead of following new ones
+func (q *Query) isBounded() bool {
+	return q.NoFollow || (q.Since == "" && q.EndTime != "")
+}
+
+func (q Query) withDefaults() Query {
+	if q.ComponentType == "" {
+		q.ComponentType = "application"
+	}
+	if q.ReorderWindow <= 0 {
+		q.ReorderWindow = constants.DefaultReorderWindow
+	}
+	return q
+}
+
+// Client streams logs, resolving the log search config of queries through the livelogs orchestrator. Clients with
+// different options can be used side by side.
+type Client struct {
+	options      Options
+	orchestrator *util.Orchestrator
+	resolver     *resolver.Resolver
+}
+
+// NewClient : client with the given options, authenticated with the token of LIVELOGS_TOKEN or else the one stored by
+// `livelogs login`
+func NewClient(options Options) (*Client, error) {
+	if options.Logger == nil {
+		options.Logger = discardLogger{}
+	}
+	orchestrator, err := util.NewOrchestrator(options.OrchestratorUrl, options.CABundle, options.Logger.Warn)
+	if err != nil {
+		return nil, errorf(ErrConfig, "error in configuring livelogs orchestrator: %w", err)
+	}
+	if options.DnsServer != "" {
+		log.Debug("Resolving host names with DNS server: " + options.DnsServer)
+	}
+	return &Client{
+		options:      options,
+		orchestrator: orchestrator,
+		resolver:     resolver.New(options.DnsServer, constants.DnsCacheTtl),
+	}, nil
+}
+
+// Session is a stream of records being read
+type Session struct {
+	// Records are the selected records, closed once the source is exhausted, the context is done or the stream failed
+	Records <-chan Record
+	// Config is the log search config the records are read with, empty for captures and recordings
+	Config models.LogSearchConfig
+
+	stats    *sessionStats
+	recorder *recording.Writer
+	logger   Logger
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err : error that ended the stream early, to be called once Records is closed
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Stats : what the session read and selected so far
+func (s *Session) Stats() Stats {
+	return s.stats.snapshot()
+}
+
+// Recorded : number of Kafka messages saved to Query.Record
+func (s *Session) Recorded() int64 {
+	if s.recorder == nil {
+		return 0
+	}
+	return s.recorder.Count()
+}
+
+// Stream : records selected by the query until the context is done, or the logs are read for a bounded query. Use
+// Open to know whether the stream ended on an error.
+func (c *Client) Stream(ctx context.Context, query Query) (<-chan Record, error) {
+	session, err := c.Open(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return session.Records, nil
+}
+
+// Open : start streaming the records selected by the query, the log search config is fetched unless the query has one
+func (c *Client) Open(ctx context.Context, query Query) (*Session, error) {
+	query = query.withDefaults()
+	recordSelector, err := newSelector(&query)
+	if err != nil {
+		return nil, err
+	}
+	query.Source, err = c.ResolveSource(query.Source)
+	if err != nil {
+		return nil, err
+	}
+	if query.Record != "" && query.Source.Kind != constants.SourceKafka {
+		return nil, errorf(ErrInvalidQuery, "--%s saves the messages read from Kafka, it needs --%s %s or the %s mode and is not available through the central livelogs agent", constants.ArgumentRecord, constants.ArgumentSource, constants.SourceKafka, constants.ModeCentral)
+	}
+
+	session := &Session{stats: newSessionStats(), logger: c.options.Logger}
+	if query.Source.IsCapture() {
+		// Captures can hold the logs of several services, so the service and component are always matched
+		recordSelector.matchComponent = true
+		source := newStdinSource(session.stats)
+		if query.Source.Kind == constants.SourceFilePrefix {
+			source = newFileSource(query.Source.Path, session.stats)
+		}
+		if err := session.start(ctx, source, recordSelector, &query); err != nil {
+			return nil, err
+		}
+		return session, nil
+	}
+
+	if query.Config != nil {
+		session.Config = *query.Config
+	} else if session.Config, err = c.ResolveConfig(query); err != nil {
+		return nil, err
+	}
+	recordSelector.matchComponent = session.Config.IsLowerEnv
+
+	var source logSource
+	if query.Source.Kind == constants.SourceKafka {
+		kafkaLogSource := newKafkaSource(&query, &session.Config, session.stats, c)
+		if query.Record != "" {
+			if kafkaLogSource.recorder, err = createRecording(&query, &session.Config); err != nil {
+				return nil, err
+			}
+			session.recorder = kafkaLogSource.recorder
+		}
+		source = kafkaLogSource
+	} else {
+		encodedFilter, err := recordSelector.filter.Encode()
+		if err != nil {
+			return nil, errorf(ErrInvalidQuery, "error in encoding filter: %w", err)
+		}
+		source = newCentralAgentSource(&query, session.Config, encodedFilter, session.stats, c)
+	}
+	if err := session.start(ctx, source, recordSelector, &query); err != nil {
+		if session.recorder != nil {
+			_ = session.recorder.Close()
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// Replay : stream the records of a recording, selected by the query as the session that recorded them selected them.
+// The records are spaced as they were consumed when originalSpeed is set.
+func (c *Client) Replay(ctx context.Context, reader *recording.Reader, query Query, originalSpeed bool) (*Session, error) {
+	metadata := reader.Metadata
+	query.ComponentType = metadata.ComponentType
+	query.ServiceName = metadata.ServiceName
+	query.ComponentName = metadata.ComponentName
+	query.AsgName = metadata.AsgName
+	query = query.withDefaults()
+
+	recordSelector, err := newSelector(&query)
+	if err != nil {
+		return nil, err
+	}
+	recordSelector.matchComponent = metadata.IsLowerEnv
+
+	session := &Session{stats: newSessionStats(), logger: c.options.Logger}
+	if err := session.start(ctx, newReplaySource(reader, query.ComponentType, originalSpeed, session.stats, c.options.Logger), recordSelector, &query); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ResolveSource : source of a query, the zero source being kafka in central mode and agent in local mode
+func (c *Client) ResolveSource(source Source) (Source, error) {
+	if source.IsCapture() {
+		return source, nil
+	}
+	runMode, err := util.ResolveMode(c.options.Mode)
+	if err != nil {
+		return Source{}, newError(ErrInvalidQuery, err)
+	}
+	switch {
+	case source.Kind == constants.SourceAgent && runMode == constants.ModeCentral:
+		return Source{}, errorf(ErrInvalidQuery, "the central livelogs agent reads from Kafka, --%s %s is only available in %s mode", constants.ArgumentSource, constants.SourceAgent, constants.ModeLocal)
+	case source.Kind != "":
+		return source, nil
+	case runMode == constants.ModeCentral:
+		log.Debug("Identified as central live log agent host")
+		return Source{Kind: constants.SourceKafka}, nil
+	}
+	log.Debug("Identified as local live log agent host")
+	return Source{Kind: constants.SourceAgent}, nil
+}
+
+// ResolveConfig : log search config of the query from the livelogs orchestrator, once its time window is checked
+// against the retention of the config
+func (c *Client) ResolveConfig(query Query) (models.LogSearchConfig, error) {
+	query = query.withDefaults()
+	config, err := c.orchestrator.GetLogsSearchConfig(query.Env, query.Org, query.Account, query.CloudProvider, query.ServiceName, query.ComponentName, query.ComponentType, query.AsgName)
+	if errors.Is(err, util.ErrNotAuthorized) {
+		return models.LogSearchConfig{}, newError(ErrAuth, err)
+	}
+	if err != nil {
+		return models.LogSearchConfig{}, newError(ErrConfig, err)
+	}
+	if err := validateWindow(&query, &config, c.options.Logger); err != nil {
+		return models.LogSearchConfig{}, err
+	}
+	return config, nil
+}
+
+// validateWindow : check the time window of the query, logging it in UTC so that timezone mistakes are visible
+// before any logs are read
+func validateWindow(query *Query, config *models.LogSearchConfig, logger Logger) error {
+	var start time.Time
+	switch {
+	case query.Since != "":
+		sinceDuration, err := time.ParseDuration(query.Since)
+		if err != nil {
+			return errorf(ErrInvalidQuery, "error in parsing since as duration: %s", query.Since)
+		}
+		if err := validateIfLogsAreAvailable(sinceDuration, config, query); err != nil {
+			return err
+		}
+		start = time.Now().Add(-sinceDuration)
+	case query.StartTime != "":
+		startTime, err := resolveTime(query.StartTime, query.Timezone)
+		if err != nil {
+			return err
+		}
+		if err := validateIfLogsAreAvailable(time.Since(startTime), config, query); err != nil {
+			return err
+		}
+		start = startTime
+	}
+
+	endText := "now (following new logs)"
+	if query.isBounded() {
+		endText = "now"
+	}
+	if query.EndTime != "" {
+		endTime, err := resolveTime(query.EndTime, query.Timezone)
+		if err != nil {
+			return err
+		}
+		if err := validateIfLogsAreAvailable(time.Since(endTime), config, query); err != nil {
+			return err
+		}
+		if query.Since == "" && !start.IsZero() {
+			if !endTime.After(start) {
+				return errorf(ErrInvalidQuery, "end time must be after start time")
+			}
+			endText = endTime.UTC().Format(time.RFC3339)
+		}
+	}
+
+	if !start.IsZero() {
+		logger.Info(fmt.Sprintf("Querying logs from %s to %s (UTC)", start.UTC().Format(time.RFC3339), endText))
+	}
+	return nil
+}
+
+func validateIfLogsAreAvailable(duration time.Duration, config *models.LogSearchConfig, query *Query) error {
+	if duration < 0 {
+		return errorf(ErrInvalidQuery, "future timestamp is not allowed")
+	}
+	if duration > time.Duration(config.MaxRetentionMinutes)*time.Minute {
+		return errorf(ErrOutOfRetention, "we store only past %v minutes data for livelogs for service: %s and component: %s, for more logs please use grafana %s", config.MaxRetentionMinutes, query.ServiceName, query.ComponentName, config.LogSearchGrafanaUrl)
+	}
+	return nil
+}
+
+// resolveTime : time expression of the query read in its timezone
+func resolveTime(expression, timezone string) (time.Time, error) {
+	resolvedTime, err := util.ResolveTime(expression, timezone)
+	if err != nil {
+		return time.Time{}, newError(ErrInvalidQuery, err)
+	}
+	return resolvedTime, nil
+}
+
+// start : read the source, selecting and ordering its records into Records until it is exhausted
+func (s *Session) start(ctx context.Context, source logSource, recordSelector *selector, query *Query) error {
+	records := make(chan Record, constants.RecordChannelSize)
+	emit := func(record *models.LogRecord) {
+		select {
+		case records <- record:
+			s.stats.recordSelected()
+		case <-ctx.Done():
+		}
+	}
+
+	ordered := query.Ordered
+	if preordered, ok := source.(orderedSource); ok && preordered.IsOrdered() {
+		ordered = false
+	}
+
+	var run func()
+	if partitioned, ok := source.(partitionedSource); ok && ordered && query.isBounded() {
+		streams, err := partitioned.Partitions(ctx)
+		if err != nil {
+			return err
+		}
+		selected := make([]<-chan *models.LogRecord, 0, len(streams))
+		for _, stream := range streams {
+			selected = append(selected, recordSelector.selectRecords(stream))
+		}
+		run = func() { mergeInOrder(selected, emit) }
+	} else {
+		input, err := source.Records(ctx)
+		if err != nil {
+			return err
+		}
+		if ordered {
+			run = func() { mergeWithWatermark(recordSelector.selectRecords(input), query.ReorderWindow, emit) }
+		} else {
+			run = func() {
+				for record := range input {
+					if recordSelector.selectRecord(record) {
+						emit(record)
+					}
+				}
+			}
+		}
+	}
+
+	s.Records = records
+	go func() {
+		defer close(records)
+		run()
+		s.finish(source.Err())
+	}()
+	return nil
+}
+
+// finish : save the recording once the source is exhausted and keep the error that ended it
+func (s *Session) finish(err error) {
+	if s.recorder != nil {
+		if closeErr := s.recorder.Close(); closeErr != nil {
+			s.logger.Warn("Failed to save recording: " + closeErr.Error())
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// createRecording : recording of the Kafka messages read by a session
+func createRecording(query *Query, logSearchConfig *models.LogSearchConfig) (*recording.Writer, error) {
+	recorder, err := recording.Create(query.Record, recording.Metadata{
+		Topic:         logSearchConfig.Topic,
+		ComponentType: query.ComponentType,
+		ServiceName:   query.ServiceName,
+		ComponentName: query.ComponentName,
+		AsgName:       query.AsgName,
+		IsLowerEnv:    logSearchConfig.IsLowerEnv,
+		RecordedAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, errorf(ErrInvalidQuery, "failed to create recording %s: %w", query.Record, err)
+	}
+	log.Debug("Recording Kafka messages to " + query.Record)
+	return recorder, nil
+}
@@ -0,0 +1,44 @@
+package livelogs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dream11/livelogs/models"
+)
+
+func TestValidateWindow(t *testing.T) {
+	config := &models.LogSearchConfig{MaxRetentionMinutes: 600}
+	anHourAgo := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	tests := []struct {
+		name  string
+		query Query
+		want  error
+	}{
+		{name: "since", query: Query{Since: "1h"}},
+		{name: "start only", query: Query{StartTime: "-2h", Timezone: "UTC"}},
+		{name: "start before end", query: Query{StartTime: "2h ago", EndTime: "1h ago", Timezone: "UTC"}},
+		{name: "start after end", query: Query{StartTime: "1h ago", EndTime: "2h ago", Timezone: "UTC"}, want: ErrInvalidQuery},
+		{name: "start equal to end", query: Query{StartTime: anHourAgo, EndTime: anHourAgo}, want: ErrInvalidQuery},
+		// --end_time does not bound a --since query, it is only checked against the retention
+		{name: "since with an earlier end", query: Query{Since: "1h", EndTime: "2h ago"}},
+		{name: "invalid since", query: Query{Since: "1d"}, want: ErrInvalidQuery},
+		{name: "future start", query: Query{StartTime: "2099-01-01", Timezone: "UTC"}, want: ErrInvalidQuery},
+		{name: "start out of retention", query: Query{StartTime: "11h ago"}, want: ErrOutOfRetention},
+		{name: "since out of retention", query: Query{Since: "11h"}, want: ErrOutOfRetention},
+		{name: "unknown timezone", query: Query{StartTime: "-1h", Timezone: "Mars/Olympus"}, want: ErrInvalidQuery},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateWindow(&test.query, config, discardLogger{})
+			if test.want == nil && err != nil {
+				t.Fatalf("validateWindow = %v, want no error", err)
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("validateWindow = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package livelogs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors returned by the client, test for them with errors.Is
+var (
+	// ErrInvalidQuery : the query can not be run as it is, such as a time, filter or source that can not be parsed
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrConfig : the log search config of the query could not be fetched from the livelogs orchestrator
+	ErrConfig = errors.New("log search config unavailable")
+	// ErrAuth : the livelogs orchestrator or the central livelogs agent refused the credentials
+	ErrAuth = errors.New("not authorized")
+	// ErrDns : a host of the log search config, Kafka brokers or the central livelogs agent, could not be resolved
+	ErrDns = errors.New("dns resolution failed")
+	// ErrOutOfRetention : the query asks for logs older than the retention of the component
+	ErrOutOfRetention = errors.New("logs out of retention")
+	// ErrNotOnboarded : the component has no topic on Log Central
+	ErrNotOnboarded = errors.New("not onboarded on Log Central")
+	// ErrKafka : the Kafka brokers of the component could not be read from
+	ErrKafka = errors.New("kafka unavailable")
+	// ErrAgent : the central livelogs agent could not be reached or its command failed
+	ErrAgent = errors.New("central livelogs agent unavailable")
+	// ErrHostKey : the central livelogs agent presented a host key that failed verification, it may be spoofed
+	ErrHostKey = errors.New("host key verification failed")
+	// ErrSource : a capture, recording or other source could not be read
+	ErrSource = errors.New("source unavailable")
+)
+
+// Error is an error of the client, Kind is one of the Err variables and Err describes what failed
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap : both the kind and the cause, so that errors.Is and errors.As see through the error
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func newError(kind error, err error) *Error {
+	return &Error{Kind: kind, Err: err}
+}
+
+func errorf(kind error, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
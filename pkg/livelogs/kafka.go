@@ -0,0 +1,354 @@
+package livelogs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/recording"
+	"github.com/dream11/livelogs/pkg/resolver"
+	"github.com/dream11/livelogs/util"
+)
+
+// kafkaSource reads the topic of a log search config, every partition being a stream of its own
+type kafkaSource struct {
+	query           *Query
+	logSearchConfig *models.LogSearchConfig
+	stats           *sessionStats
+	logger          Logger
+	resolver        *resolver.Resolver
+	// recorder saves every consumed message as it is when --record is set
+	recorder       *recording.Writer
+	recordingFails atomic.Bool
+}
+
+// consumedPartition is a partition being read, up to its end offset unless it is negative
+type consumedPartition struct {
+	partition int32
+	consumer  sarama.PartitionConsumer
+	endOffset int64
+}
+
+func newKafkaSource(query *Query, logSearchConfig *models.LogSearchConfig, stats *sessionStats, client *Client) *kafkaSource {
+	return &kafkaSource{query: query, logSearchConfig: logSearchConfig, stats: stats, logger: client.options.Logger, resolver: client.resolver}
+}
+
+// Records : records of every partition as they are consumed
+func (k *kafkaSource) Records(ctx context.Context) (<-chan *models.LogRecord, error) {
+	partitions, err := k.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mergeStreams(partitions), nil
+}
+
+// Err : the partitions are read until the context is done or their end offsets, Kafka errors are only logged
+func (k *kafkaSource) Err() error {
+	return nil
+}
+
+// Partitions : records of every partition with logs to read, the Kafka consumers are closed before the last channel is
+func (k *kafkaSource) Partitions(ctx context.Context) ([]<-chan *models.LogRecord, error) {
+	log.Debug("Reading logs from Kafka")
+
+	brokers, err := getBrokersIpFromDns(k.resolver, k.logSearchConfig.KafkaBrokerHost)
+	if err != nil {
+		return nil, err
+	}
+	samaraConfig := loadSamaraConfig()
+
+	topicExists, err := topicExists(brokers, k.logSearchConfig.Topic, samaraConfig)
+	if err != nil {
+		return nil, errorf(ErrKafka, "failed to check if topic exists in Kafka: %w", err)
+	}
+	if !topicExists {
+		return nil, errorf(ErrNotOnboarded, "env:%s service_name:%s component_name:%s is not onboarded on Log Central", k.query.Env, k.query.ServiceName, k.query.ComponentName)
+	}
+	log.Debug(fmt.Sprintf("Topic: %s exists in Kafka", k.logSearchConfig.Topic))
+
+	client, err := sarama.NewClient(brokers, samaraConfig)
+	if err != nil {
+		return nil, errorf(ErrKafka, "failed to create Kafka client: %w", err)
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, errorf(ErrKafka, "failed to create Kafka consumer: %w", err)
+	}
+
+	var consumed []consumedPartition
+	// Closing is best effort, the logs are read by then
+	closeConsumers := func() {
+		for _, partition := range consumed {
+			if err := partition.consumer.Close(); err != nil {
+				log.Debug(fmt.Sprintf("Failed to close consumer of partition %d: %v", partition.partition, err))
+			}
+		}
+		if err := consumer.Close(); err != nil {
+			log.Debug(fmt.Sprintf("Failed to close Kafka consumer: %v", err))
+		}
+		_ = client.Close()
+	}
+
+	partitions, err := consumer.Partitions(k.logSearchConfig.Topic)
+	if err != nil {
+		closeConsumers()
+		return nil, errorf(ErrKafka, "failed to get partitions for topic: %w", err)
+	}
+
+	isBounded := k.query.isBounded()
+	if isBounded {
+		log.Debug("Bounded query, reading every partition up to its end offset")
+	}
+
+	for _, partition := range partitions {
+		startOffset, err := getPartitionStartOffset(client, k.logger, k.query, k.logSearchConfig.Topic, partition)
+		if err != nil {
+			closeConsumers()
+			return nil, err
+		}
+
+		endOffset := int64(-1)
+		if isBounded {
+			if endOffset, err = getPartitionEndOffset(client, k.query, k.logSearchConfig.Topic, partition); err != nil {
+				closeConsumers()
+				return nil, err
+			}
+			if startOffset == sarama.OffsetNewest || startOffset >= endOffset {
+				log.Debug(fmt.Sprintf("Partition %d has no logs in the requested window", partition))
+				continue
+			}
+		}
+
+		partitionConsumer, err := consumer.ConsumePartition(k.logSearchConfig.Topic, partition, startOffset)
+		if err != nil {
+			closeConsumers()
+			return nil, errorf(ErrKafka, "failed to create partition consumer: %w", err)
+		}
+		consumed = append(consumed, consumedPartition{partition: partition, consumer: partitionConsumer, endOffset: endOffset})
+
+		go func(partition int32, partitionConsumer sarama.PartitionConsumer) {
+			for err := range partitionConsumer.Errors() {
+				log.Debug(fmt.Sprintf("Error while consuming partition %d: %v", partition, err))
+			}
+		}(partition, partitionConsumer)
+	}
+
+	if len(consumed) == 0 {
+		closeConsumers()
+		return nil, nil
+	}
+
+	var remaining atomic.Int32
+	remaining.Store(int32(len(consumed)))
+	streams := make([]<-chan *models.LogRecord, 0, len(consumed))
+	for _, partition := range consumed {
+		records := make(chan *models.LogRecord, constants.RecordChannelSize)
+		streams = append(streams, records)
+
+		go func(partition consumedPartition) {
+			defer close(records)
+			k.consumePartition(ctx, partition, records)
+			if remaining.Add(-1) == 0 {
+				log.Debug("All partitions are drained")
+				closeConsumers()
+			}
+		}(partition)
+	}
+	return streams, nil
+}
+
+// consumePartition : decode messages until the end offset is reached, a negative end offset follows the partition forever
+func (k *kafkaSource) consumePartition(ctx context.Context, partition consumedPartition, records chan<- *models.LogRecord) {
+	endOffset := partition.endOffset
+	var idleTimer <-chan time.Time
+	if endOffset >= 0 {
+		timer := time.NewTimer(constants.BoundedQueryIdleTimeout)
+		defer timer.Stop()
+		idleTimer = timer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case eachMessage, ok := <-partition.consumer.Messages():
+			if !ok {
+				return
+			}
+			if endOffset >= 0 && eachMessage.Offset >= endOffset {
+				return
+			}
+			k.stats.recordRead(partition.partition, eachMessage.Offset)
+			if k.recorder != nil {
+				k.record(eachMessage)
+			}
+
+			if record := decodeMessage(eachMessage, k.query.ComponentType); record != nil {
+				select {
+				case records <- record:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if endOffset >= 0 {
+				if eachMessage.Offset+1 >= endOffset {
+					log.Debug(fmt.Sprintf("Partition %d reached its end offset %d", partition.partition, endOffset))
+					return
+				}
+				idleTimer = time.After(constants.BoundedQueryIdleTimeout)
+			}
+		case <-idleTimer:
+			// Offsets can have gaps (transaction markers, compaction), so an idle partition is treated as drained
+			log.Debug(fmt.Sprintf("Partition %d is idle before its end offset %d, treating it as drained", partition.partition, endOffset))
+			return
+		}
+	}
+}
+
+// record : save a consumed message to the recording, a failure is only reported once
+func (k *kafkaSource) record(consumerMsg *sarama.ConsumerMessage) {
+	record := recording.Record{
+		Topic:     consumerMsg.Topic,
+		Partition: consumerMsg.Partition,
+		Offset:    consumerMsg.Offset,
+		Timestamp: consumerMsg.Timestamp,
+		Key:       consumerMsg.Key,
+		Value:     consumerMsg.Value,
+	}
+	for _, header := range consumerMsg.Headers {
+		if header != nil {
+			record.Headers = append(record.Headers, recording.Header{Key: header.Key, Value: header.Value})
+		}
+	}
+	if err := k.recorder.Write(record); err != nil && !k.recordingFails.Swap(true) {
+		k.logger.Warn("Failed to record Kafka messages: " + err.Error())
+	}
+}
+
+// getBrokersIpFromDns : broker addresses from an SRV name such as _kafka._tcp.example.com, or from the IPs of a
+// host name with its port, 9092 when it has none
+func getBrokersIpFromDns(dnsResolver *resolver.Resolver, hostname string) ([]string, error) {
+	log.Debug("Resolving DNS for Kafka brokers from hostname: " + hostname)
+	brokers, err := util.GetAddressesFromHost(dnsResolver, hostname, constants.KafkaBrokerPort)
+	if err != nil {
+		return nil, errorf(ErrDns, "error in resolving Kafka brokers of %s: %w", hostname, err)
+	}
+	if len(brokers) == 0 {
+		return nil, errorf(ErrDns, "no Kafka brokers found for %s", hostname)
+	}
+
+	log.Debug("Resolved Kafka brokers: " + strings.Join(brokers, ", "))
+	return brokers, nil
+}
+
+func loadSamaraConfig() *sarama.Config {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	config.Version = sarama.V2_8_0_0
+	return config
+}
+
+func topicExists(brokerAddresses []string, topicName string, config *sarama.Config) (bool, error) {
+	log.Debug(fmt.Sprintf("Checking if topic %s exists in Kafka", topicName))
+	adminClient, err := sarama.NewClusterAdmin(brokerAddresses, config)
+	if err != nil {
+		return false, fmt.Errorf("failed to create Kafka admin client: %w", err)
+	}
+	defer adminClient.Close()
+
+	topics, err := adminClient.ListTopics()
+	if err != nil {
+		return false, fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	var topicNames []string
+	for key := range topics {
+		topicNames = append(topicNames, key)
+	}
+
+	log.Debug(fmt.Sprintf("Topics in Kafka: %v", topicNames))
+	_, exists := topics[topicName]
+	return exists, nil
+}
+
+func getPartitionStartOffset(client sarama.Client, logger Logger, query *Query, topic string, partition int32) (int64, error) {
+	if resumeOffset, ok := query.ResumeOffsets[partition]; ok {
+		return getResumeOffset(client, logger, topic, partition, resumeOffset)
+	}
+
+	var startTime time.Time
+	if query.Since != "" {
+		duration, err := time.ParseDuration(query.Since)
+		if err != nil {
+			return 0, errorf(ErrInvalidQuery, "error in parsing duration for since: %s", query.Since)
+		}
+		startTime = time.Now().Add(-duration)
+	} else if query.StartTime != "" {
+		var err error
+		if startTime, err = resolveTime(query.StartTime, query.Timezone); err != nil {
+			return 0, err
+		}
+	} else {
+		return sarama.OffsetNewest, nil
+	}
+
+	startOffset, err := client.GetOffset(topic, partition, startTime.UnixMilli())
+	if err != nil {
+		return 0, errorf(ErrKafka, "failed to fetch offset from timestamp: %w", err)
+	}
+	return startOffset, nil
+}
+
+// getResumeOffset : saved offset of a partition, moved to the oldest available offset when retention already deleted it
+func getResumeOffset(client sarama.Client, logger Logger, topic string, partition int32, resumeOffset int64) (int64, error) {
+	oldestOffset, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, errorf(ErrKafka, "failed to fetch oldest offset of partition %d: %w", partition, err)
+	}
+	if resumeOffset < oldestOffset {
+		logger.Warn(fmt.Sprintf("Saved offset %d of partition %d is no longer retained, resuming from offset %d", resumeOffset, partition, oldestOffset))
+		return oldestOffset, nil
+	}
+
+	newestOffset, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, errorf(ErrKafka, "failed to fetch high watermark of partition %d: %w", partition, err)
+	}
+	if resumeOffset > newestOffset {
+		return newestOffset, nil
+	}
+	return resumeOffset, nil
+}
+
+// getPartitionEndOffset : snapshot of the offset after the last message to read, the high watermark unless the end time is earlier
+func getPartitionEndOffset(client sarama.Client, query *Query, topic string, partition int32) (int64, error) {
+	endOffset, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, errorf(ErrKafka, "failed to fetch high watermark of partition %d: %w", partition, err)
+	}
+
+	if query.Since == "" && query.EndTime != "" {
+		endTime, err := resolveTime(query.EndTime, query.Timezone)
+		if err != nil {
+			return 0, err
+		}
+		endTimeOffset, err := client.GetOffset(topic, partition, endTime.UnixMilli())
+		if err != nil {
+			return 0, errorf(ErrKafka, "failed to fetch offset from timestamp: %w", err)
+		}
+		// An offset of -1 means no message is newer than the end time, so the high watermark is the end
+		if endTimeOffset >= 0 && endTimeOffset < endOffset {
+			endOffset = endTimeOffset
+		}
+	}
+	return endOffset, nil
+}
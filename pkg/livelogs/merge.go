@@ -0,0 +1,123 @@
+package livelogs
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+)
+
+// recordHeap orders records by timestamp, then partition and offset so that ties are deterministic
+type recordHeap []*heapEntry
+
+type heapEntry struct {
+	record *models.LogRecord
+	input  int
+}
+
+func (h recordHeap) Len() int { return len(h) }
+
+func (h recordHeap) Less(i, j int) bool {
+	return isRecordBefore(h[i].record, h[j].record)
+}
+
+func (h recordHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *recordHeap) Push(x interface{}) { *h = append(*h, x.(*heapEntry)) }
+
+func (h *recordHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+func isRecordBefore(a, b *models.LogRecord) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	if a.Partition != b.Partition {
+		return a.Partition < b.Partition
+	}
+	return a.Offset < b.Offset
+}
+
+// mergeInOrder : k-way merge of partition streams, each input is read one record at a time so the output is a strict
+// total order as long as every partition is itself in timestamp order. Returns once every input is closed.
+func mergeInOrder(inputs []<-chan *models.LogRecord, emit func(*models.LogRecord)) {
+	heads := &recordHeap{}
+	for index, input := range inputs {
+		if record, ok := <-input; ok {
+			heap.Push(heads, &heapEntry{record: record, input: index})
+		}
+	}
+
+	for heads.Len() > 0 {
+		entry := heap.Pop(heads).(*heapEntry)
+		emit(entry.record)
+		if record, ok := <-inputs[entry.input]; ok {
+			heap.Push(heads, &heapEntry{record: record, input: entry.input})
+		}
+	}
+}
+
+// partitionProgress is how far a partition has been read, by event time, and when it last delivered a record
+type partitionProgress struct {
+	latest time.Time
+	seenAt time.Time
+}
+
+// mergeWithWatermark : buffers records and emits them in timestamp order once they are behind the watermark, which
+// trails the latest event time of the slowest partition by the reordering window. Event times rather than the clock
+// drive it so that a backlog is ordered like live records are. Partitions that deliver nothing for a window are not
+// waited for, and records arriving behind the watermark are emitted immediately. Returns once the input is closed.
+func mergeWithWatermark(input <-chan *models.LogRecord, window time.Duration, emit func(*models.LogRecord)) {
+	buffer := &recordHeap{}
+	progress := map[int32]*partitionProgress{}
+	ticker := time.NewTicker(constants.OrderedMergeFlushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		now := time.Now()
+		var watermark time.Time
+		waiting := false
+		for _, partition := range progress {
+			if now.Sub(partition.seenAt) > window {
+				continue
+			}
+			if !waiting || partition.latest.Before(watermark) {
+				watermark, waiting = partition.latest, true
+			}
+		}
+		watermark = watermark.Add(-window)
+		for buffer.Len() > 0 && (!waiting || !(*buffer)[0].record.Timestamp.After(watermark)) {
+			emit(heap.Pop(buffer).(*heapEntry).record)
+		}
+	}
+
+	for {
+		select {
+		case record, ok := <-input:
+			if !ok {
+				for buffer.Len() > 0 {
+					emit(heap.Pop(buffer).(*heapEntry).record)
+				}
+				return
+			}
+			partition, found := progress[record.Partition]
+			if !found {
+				partition = &partitionProgress{}
+				progress[record.Partition] = partition
+			}
+			if record.Timestamp.After(partition.latest) {
+				partition.latest = record.Timestamp
+			}
+			partition.seenAt = time.Now()
+			heap.Push(buffer, &heapEntry{record: record})
+			flush()
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
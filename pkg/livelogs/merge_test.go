@@ -0,0 +1,185 @@
+package livelogs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dream11/livelogs/models"
+)
+
+var mergeEpoch = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// record : record of a partition at an offset, its timestamp is seconds after mergeEpoch
+func record(partition int32, offset int64, seconds int) *models.LogRecord {
+	return &models.LogRecord{Partition: partition, Offset: offset, Timestamp: mergeEpoch.Add(time.Duration(seconds) * time.Second)}
+}
+
+func partitionStream(records ...*models.LogRecord) <-chan *models.LogRecord {
+	stream := make(chan *models.LogRecord, len(records))
+	for _, record := range records {
+		stream <- record
+	}
+	close(stream)
+	return stream
+}
+
+// describe : records as partition/offset@seconds, for readable failures
+func describe(records []*models.LogRecord) string {
+	parts := make([]string, 0, len(records))
+	for _, record := range records {
+		parts = append(parts, fmt.Sprintf("%d/%d@%d", record.Partition, record.Offset, int(record.Timestamp.Sub(mergeEpoch).Seconds())))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestMergeInOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs [][]*models.LogRecord
+		want   string
+	}{
+		{
+			name:   "interleaved partitions",
+			inputs: [][]*models.LogRecord{{record(0, 0, 1), record(0, 1, 4), record(0, 2, 7)}, {record(1, 0, 2), record(1, 1, 3)}, {record(2, 0, 5)}},
+			want:   "0/0@1 1/0@2 1/1@3 0/1@4 2/0@5 0/2@7",
+		},
+		{
+			name:   "equal timestamps are ordered by partition then offset",
+			inputs: [][]*models.LogRecord{{record(2, 8, 1), record(2, 9, 1)}, {record(0, 5, 1)}, {record(1, 3, 0), record(1, 4, 1)}},
+			want:   "1/3@0 0/5@1 1/4@1 2/8@1 2/9@1",
+		},
+		{
+			name:   "empty partitions",
+			inputs: [][]*models.LogRecord{{}, {record(1, 0, 1)}, {}},
+			want:   "1/0@1",
+		},
+		{
+			name:   "no partitions",
+			inputs: nil,
+			want:   "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputs := make([]<-chan *models.LogRecord, 0, len(test.inputs))
+			for _, records := range test.inputs {
+				inputs = append(inputs, partitionStream(records...))
+			}
+			var got []*models.LogRecord
+			mergeInOrder(inputs, func(record *models.LogRecord) { got = append(got, record) })
+			if describe(got) != test.want {
+				t.Errorf("merged = %s, want %s", describe(got), test.want)
+			}
+		})
+	}
+}
+
+// watermarkMerge runs mergeWithWatermark on an input fed by the test
+type watermarkMerge struct {
+	input   chan *models.LogRecord
+	emitted chan *models.LogRecord
+	done    chan struct{}
+}
+
+func startWatermarkMerge(window time.Duration) *watermarkMerge {
+	merge := &watermarkMerge{
+		input:   make(chan *models.LogRecord),
+		emitted: make(chan *models.LogRecord, 100),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(merge.done)
+		mergeWithWatermark(merge.input, window, func(record *models.LogRecord) { merge.emitted <- record })
+	}()
+	return merge
+}
+
+func (m *watermarkMerge) send(records ...*models.LogRecord) {
+	for _, record := range records {
+		m.input <- record
+	}
+}
+
+// expect : wait for the next records to be emitted
+func (m *watermarkMerge) expect(t *testing.T, want string) {
+	t.Helper()
+	var got []*models.LogRecord
+	for want != "" && len(got) < len(strings.Fields(want)) {
+		select {
+		case record := <-m.emitted:
+			got = append(got, record)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("emitted %s, want %s", describe(got), want)
+		}
+	}
+	if describe(got) != want {
+		t.Fatalf("emitted %s, want %s", describe(got), want)
+	}
+}
+
+// expectNothing : no record is emitted within a few flush intervals
+func (m *watermarkMerge) expectNothing(t *testing.T) {
+	t.Helper()
+	select {
+	case record := <-m.emitted:
+		t.Fatalf("emitted %s, want nothing yet", describe([]*models.LogRecord{record}))
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func (m *watermarkMerge) close(t *testing.T) {
+	t.Helper()
+	close(m.input)
+	select {
+	case <-m.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("mergeWithWatermark did not return once its input was closed")
+	}
+}
+
+func TestMergeWithWatermarkFlushesOnClose(t *testing.T) {
+	merge := startWatermarkMerge(time.Hour)
+	merge.send(record(1, 0, 30), record(0, 0, 20), record(1, 1, 10), record(0, 1, 10), record(2, 0, 10))
+	merge.expectNothing(t)
+	merge.close(t)
+	// Equal timestamps come out by partition then offset
+	merge.expect(t, "0/1@10 1/1@10 2/0@10 0/0@20 1/0@30")
+}
+
+func TestMergeWithWatermarkSlowPartitionHoldsBackTheWatermark(t *testing.T) {
+	// The window is long enough for no partition to count as idle during the test
+	window := 10 * time.Minute
+	merge := startWatermarkMerge(window)
+
+	// Partition 0 is an hour behind partition 1, the watermark follows it
+	merge.send(record(0, 0, 0), record(1, 0, 3600), record(1, 1, 7200))
+	merge.expectNothing(t)
+
+	// Once partition 0 catches up, what is a window behind the slowest partition is emitted
+	merge.send(record(0, 1, 7200))
+	merge.expect(t, "0/0@0 1/0@3600")
+	merge.expectNothing(t)
+
+	// A late record of a partition already seen is behind the watermark, it is emitted as soon as it arrives
+	merge.send(record(1, 2, 60))
+	merge.expect(t, "1/2@60")
+
+	merge.close(t)
+	merge.expect(t, "0/1@7200 1/1@7200")
+}
+
+func TestMergeWithWatermarkIdlePartitionsAreNotWaitedFor(t *testing.T) {
+	window := time.Second
+	merge := startWatermarkMerge(window)
+
+	merge.send(record(0, 0, 0), record(1, 0, 3600))
+	// Partition 0 holds partition 1 back while it is active, for a window after its last record
+	merge.expectNothing(t)
+
+	// Once every partition has been idle for a window, everything buffered is emitted without closing the input
+	merge.expect(t, "0/0@0 1/0@3600")
+	merge.close(t)
+}
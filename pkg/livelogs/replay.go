@@ -0,0 +1,91 @@
+package livelogs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/recording"
+)
+
+// replaySource decodes the records of a recording as if they were consumed from Kafka
+type replaySource struct {
+	reader        *recording.Reader
+	componentType string
+	originalSpeed bool
+	stats         *sessionStats
+	logger        Logger
+}
+
+func newReplaySource(reader *recording.Reader, componentType string, originalSpeed bool, stats *sessionStats, logger Logger) *replaySource {
+	return &replaySource{reader: reader, componentType: componentType, originalSpeed: originalSpeed, stats: stats, logger: logger}
+}
+
+// Records : records of the recording in the order they were consumed, spaced as they were when replaying at original speed
+func (r *replaySource) Records(ctx context.Context) (<-chan *models.LogRecord, error) {
+	records := make(chan *models.LogRecord, constants.RecordChannelSize)
+	go func() {
+		defer close(records)
+		var firstTimestamp time.Time
+		startTime := time.Now()
+		for {
+			recorded, err := r.reader.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				r.logger.Warn("Recording ends with an unreadable record: " + err.Error())
+				return
+			}
+
+			if r.originalSpeed && !recorded.Timestamp.IsZero() {
+				if firstTimestamp.IsZero() {
+					firstTimestamp = recorded.Timestamp
+				}
+				if wait := recorded.Timestamp.Sub(firstTimestamp) - time.Since(startTime); wait > 0 {
+					select {
+					case <-time.After(wait):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			r.stats.recordRead(recorded.Partition, recorded.Offset)
+			record := decodeMessage(consumerMessage(recorded), r.componentType)
+			if record == nil {
+				continue
+			}
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return records, nil
+}
+
+// Err : a truncated recording is replayed up to its last readable record, which is not an error
+func (r *replaySource) Err() error {
+	return nil
+}
+
+func consumerMessage(recorded *recording.Record) *sarama.ConsumerMessage {
+	consumerMsg := &sarama.ConsumerMessage{
+		Topic:     recorded.Topic,
+		Partition: recorded.Partition,
+		Offset:    recorded.Offset,
+		Timestamp: recorded.Timestamp,
+		Key:       recorded.Key,
+		Value:     recorded.Value,
+	}
+	for _, header := range recorded.Headers {
+		consumerMsg.Headers = append(consumerMsg.Headers, &sarama.RecordHeader{Key: header.Key, Value: header.Value})
+	}
+	return consumerMsg
+}
@@ -0,0 +1,300 @@
+package livelogs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/filter"
+	"github.com/dream11/livelogs/pkg/severity"
+	"github.com/dream11/livelogs/protobuf"
+	"github.com/dream11/livelogs/util"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// selector keeps the records of a source that are selected by a query
+type selector struct {
+	query *Query
+	// matchComponent is set when the records are only kept for the service and component asked for, as the
+	// topics of lower environments are shared by several services
+	matchComponent bool
+	showTagsArray  []string
+	levelMapper    *severity.Mapper
+	levelSelector  *severity.Selector
+	filter         *filter.Filter
+}
+
+func newSelector(query *Query) (*selector, error) {
+	levelMapper, err := severity.NewMapper(query.LevelMapping)
+	if err != nil {
+		return nil, newError(ErrInvalidQuery, err)
+	}
+
+	levelSelector, err := severity.NewSelector(query.Level)
+	if err != nil {
+		return nil, newError(ErrInvalidQuery, err)
+	}
+
+	var recordFilter *filter.Filter
+	if query.Filter != "" {
+		recordFilter, err = filter.Parse(query.Filter)
+	} else {
+		recordFilter, err = filter.Decode(query.EncodedFilter)
+	}
+	if err != nil {
+		return nil, errorf(ErrInvalidQuery, "invalid filter: %w", err)
+	}
+
+	return &selector{
+		query:         query,
+		showTagsArray: strings.Split(query.ShowTags, ","),
+		levelMapper:   levelMapper,
+		levelSelector: levelSelector,
+		filter:        recordFilter,
+	}, nil
+}
+
+// getRecordTimestamp : event time set by the producer, falling back to the kafka message time when it is missing
+func getRecordTimestamp(eventTime *timestamppb.Timestamp, consumerMsg *sarama.ConsumerMessage) time.Time {
+	if eventTime != nil && (eventTime.Seconds != 0 || eventTime.Nanos != 0) {
+		return eventTime.AsTime()
+	}
+	return consumerMsg.Timestamp
+}
+
+// decodeMessage : record of a consumed message, nil when it can not be decoded as the component type
+func decodeMessage(consumerMsg *sarama.ConsumerMessage, componentType string) *models.LogRecord {
+	record := &models.LogRecord{
+		Topic:     consumerMsg.Topic,
+		Partition: consumerMsg.Partition,
+		Offset:    consumerMsg.Offset,
+	}
+
+	if componentType == "application" {
+		record.Application = decodeApplicationLogs(consumerMsg)
+		if record.Application == nil {
+			return nil
+		}
+		record.Timestamp = record.Application.Timestamp
+	} else if componentType == "asg" {
+		record.Asg = decodeAsgLogs(consumerMsg)
+		if record.Asg == nil {
+			return nil
+		}
+		record.Timestamp = record.Asg.Timestamp
+	} else {
+		return nil
+	}
+	return record
+}
+
+// selectRecords : records of a stream selected by the query, the channel is closed with the stream
+func (s *selector) selectRecords(records <-chan *models.LogRecord) <-chan *models.LogRecord {
+	selected := make(chan *models.LogRecord, constants.RecordChannelSize)
+	go func() {
+		defer close(selected)
+		for record := range records {
+			if s.selectRecord(record) {
+				selected <- record
+			}
+		}
+	}()
+	return selected
+}
+
+// selectRecord : whether a record is selected by the query, resolving its level when the source did not. Text lines
+// of a central livelogs agent were selected by the agent already.
+func (s *selector) selectRecord(record *models.LogRecord) bool {
+	if record.Text != "" {
+		return true
+	}
+	if record.Asg != nil {
+		return (s.query.AsgName == "" || strings.Contains(strings.ToLower(s.query.AsgName), record.Asg.AutoScalingGroupName)) &&
+			s.filter.Match(asgLogFields(record.Asg))
+	}
+	logsStruct := record.Application
+	if logsStruct == nil {
+		return false
+	}
+
+	tags := ddtagsOf(logsStruct)
+	if tags != nil {
+		logsStruct.Ddtags = tags
+	}
+
+	level, known := severity.Parse(logsStruct.Level)
+	if !known {
+		message, _ := logsStruct.Message.(string)
+		level = s.levelMapper.Resolve(logsStruct.Status, message)
+		logsStruct.Level = level.String()
+	}
+
+	shouldPrint := !s.matchComponent || (s.query.ServiceName == "" && s.query.ComponentName == "") ||
+		(logsStruct.Service != "" && strings.EqualFold(logsStruct.Service, s.query.ServiceName) &&
+			(s.query.ComponentName == "" || (logsStruct.Service != "" && strings.EqualFold(logsStruct.ComponentName, s.query.ComponentName))))
+
+	// The filter sees every tag, --show_tags only trims what is shown
+	if !shouldPrint || !s.levelSelector.Matches(level) || !s.filter.Match(applicationLogFields(logsStruct)) {
+		return false
+	}
+	if s.query.ShowTags != "" {
+		for key := range tags {
+			if !isDdTagAllowed(key, s.showTagsArray) {
+				delete(tags, key)
+			}
+		}
+	}
+	return true
+}
+
+// ddtagsOf : tags of a record as a map, records decoded from JSON hold them as a map of interfaces
+func ddtagsOf(logsStruct *models.VectorLogsStruct) map[string]string {
+	switch tags := logsStruct.Ddtags.(type) {
+	case map[string]string:
+		return tags
+	case map[string]interface{}:
+		converted := make(map[string]string, len(tags))
+		for key, value := range tags {
+			converted[key] = fmt.Sprint(value)
+		}
+		return converted
+	}
+	return nil
+}
+
+func decodeAsgLogs(consumerMsg *sarama.ConsumerMessage) *models.AsgLogsStruct {
+	var asgLogs = &protobuf.AsgLogs{}
+	if err := proto.Unmarshal(consumerMsg.Value, asgLogs); err != nil {
+		log.Debug(fmt.Sprintf("Failed to decode message value: %v Error: %v", consumerMsg.Value, err))
+		return nil
+	}
+
+	return &models.AsgLogsStruct{
+		AccountId:            asgLogs.AccountId,
+		AutoScalingGroupName: asgLogs.AutoScalingGroupName,
+		Details:              asgLogs.Details,
+		ActivityId:           asgLogs.ActivityId,
+		RequestId:            asgLogs.RequestId,
+		Progress:             asgLogs.Progress,
+		Event:                asgLogs.Event,
+		StatusCode:           asgLogs.StatusCode,
+		StatusMessage:        asgLogs.StatusMessage,
+		Description:          asgLogs.Description,
+		Cause:                asgLogs.Cause,
+		StartTime:            asgLogs.StartTime,
+		EndTime:              asgLogs.EndTime,
+		EC2InstanceId:        asgLogs.Ec2InstanceId,
+		Timestamp:            getRecordTimestamp(asgLogs.Timestamp, consumerMsg),
+	}
+}
+
+// decodeApplicationLogs : application log of a consumed message, its level is resolved when the record is selected
+func decodeApplicationLogs(consumerMsg *sarama.ConsumerMessage) *models.VectorLogsStruct {
+	var vectorLogs = &protobuf.VectorLogs{}
+	if err := proto.Unmarshal(consumerMsg.Value, vectorLogs); err != nil {
+		log.Debug("Failed to decode message value. Error: " + err.Error())
+		return nil
+	}
+
+	return &models.VectorLogsStruct{
+		Message:       vectorLogs.Message,
+		Hostname:      util.DereferenceString(vectorLogs.Hostname),
+		Env:           vectorLogs.Env,
+		ComponentName: vectorLogs.ComponentName,
+		Service:       vectorLogs.ServiceName,
+		Ddtags:        vectorLogs.Ddtags,
+		Ddsource:      util.DereferenceString(vectorLogs.Ddsource),
+		SourceType:    util.DereferenceString(vectorLogs.SourceType),
+		Timestamp:     getRecordTimestamp(vectorLogs.Timestamp, consumerMsg),
+		Status:        util.DereferenceString(vectorLogs.Status),
+		Extra:         vectorLogs.Extra,
+	}
+}
+
+// applicationLogFields : fields of an application log record that can be used in filter expressions
+func applicationLogFields(logsStruct *models.VectorLogsStruct) filter.Fields {
+	return filter.FieldsFunc(func(name string) (string, bool) {
+		if key, found := strings.CutPrefix(name, "ddtags."); found {
+			tags, _ := logsStruct.Ddtags.(map[string]string)
+			value, ok := tags[key]
+			return value, ok
+		}
+		if key, found := strings.CutPrefix(name, "extra."); found {
+			value, ok := logsStruct.Extra[key]
+			return value, ok
+		}
+
+		switch name {
+		case "message":
+			message, ok := logsStruct.Message.(string)
+			return message, ok
+		case "level":
+			return logsStruct.Level, true
+		case "status":
+			return logsStruct.Status, logsStruct.Status != ""
+		case "service", "service_name":
+			return logsStruct.Service, true
+		case "component", "component_name":
+			return logsStruct.ComponentName, true
+		case "env":
+			return logsStruct.Env, true
+		case "host", "hostname":
+			return logsStruct.Hostname, true
+		case "ddsource":
+			return logsStruct.Ddsource, true
+		case "source_type":
+			return logsStruct.SourceType, true
+		}
+		return "", false
+	})
+}
+
+// asgLogFields : fields of an ASG event that can be used in filter expressions, message is the description shown
+// in text output
+func asgLogFields(asgLogs *models.AsgLogsStruct) filter.Fields {
+	return filter.FieldsFunc(func(name string) (string, bool) {
+		var value string
+		switch name {
+		case "message", "description":
+			value = asgLogs.Description
+		case "asg_name":
+			value = asgLogs.AutoScalingGroupName
+		case "account_id":
+			value = asgLogs.AccountId
+		case "event":
+			value = asgLogs.Event
+		case "status_code":
+			value = asgLogs.StatusCode
+		case "status_message":
+			value = asgLogs.StatusMessage
+		case "cause":
+			value = asgLogs.Cause
+		case "details":
+			value = asgLogs.Details
+		case "progress":
+			value = asgLogs.Progress
+		case "activity_id":
+			value = asgLogs.ActivityId
+		case "request_id":
+			value = asgLogs.RequestId
+		case "ec2_instance_id":
+			value = asgLogs.EC2InstanceId
+		default:
+			return "", false
+		}
+		return value, value != ""
+	})
+}
+
+func isDdTagAllowed(tag string, allowedTags []string) bool {
+	for _, s := range allowedTags {
+		if s == tag {
+			return true
+		}
+	}
+	return false
+}
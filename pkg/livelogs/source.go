@@ -0,0 +1,84 @@
+package livelogs
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+)
+
+// Source is where records are read from, the zero value is chosen from the run mode of the client
+type Source struct {
+	// Kind is kafka, agent, stdin or file:
+	Kind string
+	// Path is the capture read by a file: source
+	Path string
+}
+
+// ParseSource : source named like --source, one of kafka, agent, stdin or file:<path>, empty to choose it from the run mode
+func ParseSource(spec string) (Source, error) {
+	if path, found := strings.CutPrefix(spec, constants.SourceFilePrefix); found {
+		if path == "" {
+			return Source{}, errorf(ErrInvalidQuery, "--%s %s needs a file path", constants.ArgumentSource, constants.SourceFilePrefix)
+		}
+		return Source{Kind: constants.SourceFilePrefix, Path: path}, nil
+	}
+	switch strings.ToLower(spec) {
+	case "":
+		return Source{}, nil
+	case constants.SourceKafka, constants.SourceAgent, constants.SourceStdin:
+		return Source{Kind: strings.ToLower(spec)}, nil
+	}
+	return Source{}, errorf(ErrInvalidQuery, "invalid --%s %q, must be one of: %s, %s, %s, %s<path>", constants.ArgumentSource, spec,
+		constants.SourceKafka, constants.SourceAgent, constants.SourceStdin, constants.SourceFilePrefix)
+}
+
+// IsCapture : whether the source is a capture of records, which needs neither the orchestrator nor the network
+func (s Source) IsCapture() bool {
+	return s.Kind == constants.SourceStdin || s.Kind == constants.SourceFilePrefix
+}
+
+// logSource yields decoded records, whatever they are read from. Selecting and ordering them is the same for every
+// source.
+type logSource interface {
+	// Records : start reading, the channel is closed once the source is exhausted or the context is done
+	Records(ctx context.Context) (<-chan *models.LogRecord, error)
+	// Err : error that ended the records before the source was exhausted, to be called once they are all read
+	Err() error
+}
+
+// partitionedSource is a source made of several streams that are each in timestamp order, such as the partitions of a
+// Kafka topic, so that a bounded read can be merged in a strict total order
+type partitionedSource interface {
+	logSource
+	// Partitions : start reading, one channel per stream, each closed once its stream is exhausted or the context is done
+	Partitions(ctx context.Context) ([]<-chan *models.LogRecord, error)
+}
+
+// orderedSource is a source that already delivers its records in the order asked for
+type orderedSource interface {
+	logSource
+	IsOrdered() bool
+}
+
+// mergeStreams : single channel carrying the records of every stream, closed once they all are
+func mergeStreams(streams []<-chan *models.LogRecord) <-chan *models.LogRecord {
+	merged := make(chan *models.LogRecord, constants.RecordChannelSize)
+	var wg sync.WaitGroup
+	for _, stream := range streams {
+		wg.Add(1)
+		go func(stream <-chan *models.LogRecord) {
+			defer wg.Done()
+			for record := range stream {
+				merged <- record
+			}
+		}(stream)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+	return merged
+}
@@ -0,0 +1,76 @@
+package livelogs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts what a session read and selected
+type Stats struct {
+	StartTime       time.Time
+	RecordsRead     int64
+	RecordsSelected int64
+	// Uncounted is set when the records were text lines of a central livelogs agent, which stand for an unknown number
+	// of records read and shown, RecordsRead and RecordsSelected are then zero
+	Uncounted bool
+	// Offsets are the last offset read from every partition
+	Offsets map[int32]int64
+}
+
+// sessionStats is updated by the sources and the selection of a session as records flow
+type sessionStats struct {
+	startTime       time.Time
+	recordsRead     atomic.Int64
+	recordsSelected atomic.Int64
+	uncounted       atomic.Bool
+
+	mu          sync.Mutex
+	lastOffsets map[int32]int64
+}
+
+func newSessionStats() *sessionStats {
+	return &sessionStats{startTime: time.Now(), lastOffsets: map[int32]int64{}}
+}
+
+func (s *sessionStats) recordRead(partition int32, offset int64) {
+	s.recordsRead.Add(1)
+	s.recordOffsets(map[int32]int64{partition: offset})
+}
+
+// recordOffsets : last offsets processed by a source that reports them instead of reading every record
+func (s *sessionStats) recordOffsets(offsets map[int32]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for partition, offset := range offsets {
+		s.lastOffsets[partition] = offset
+	}
+}
+
+// markUncounted : the records of the session can not be counted
+func (s *sessionStats) markUncounted() {
+	s.uncounted.Store(true)
+}
+
+func (s *sessionStats) recordSelected() {
+	s.recordsSelected.Add(1)
+}
+
+func (s *sessionStats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets := make(map[int32]int64, len(s.lastOffsets))
+	for partition, offset := range s.lastOffsets {
+		offsets[partition] = offset
+	}
+	stats := Stats{
+		StartTime:       s.startTime,
+		RecordsRead:     s.recordsRead.Load(),
+		RecordsSelected: s.recordsSelected.Load(),
+		Offsets:         offsets,
+	}
+	if s.uncounted.Load() {
+		stats.RecordsRead, stats.RecordsSelected, stats.Uncounted = 0, 0, true
+	}
+	return stats
+}
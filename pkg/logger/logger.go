@@ -2,13 +2,22 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/mitchellh/cli"
 )
 
 type Logger struct{}
 
+var basicUi = &cli.BasicUi{
+	Writer:      os.Stdout,
+	ErrorWriter: os.Stdout,
+	Reader:      os.Stdin,
+}
+
 var userInterface = &cli.PrefixedUi{
 	AskPrefix:       "",
 	AskSecretPrefix: "[ SECRET ] ",
@@ -16,11 +25,14 @@ var userInterface = &cli.PrefixedUi{
 	InfoPrefix:      "",
 	ErrorPrefix:     "[ ERROR ] ",
 	WarnPrefix:      "[ WARNING ] ",
-	Ui: &cli.BasicUi{
-		Writer:      os.Stdout,
-		ErrorWriter: os.Stdout,
-		Reader:      os.Stdin,
-	},
+	Ui:              basicUi,
+}
+
+// statusUi prompts on stderr, for questions asked while records are written to stdout
+var statusUi = &cli.BasicUi{
+	Writer:      os.Stderr,
+	ErrorWriter: os.Stderr,
+	Reader:      os.Stdin,
 }
 
 const (
@@ -32,6 +44,32 @@ const (
 
 var isDebugModeEnabled = false
 
+const redactedSecret = "[REDACTED]"
+
+var (
+	secretsMutex sync.RWMutex
+	secrets      []string
+)
+
+// RegisterSecret : mask every occurrence of the value in debug messages
+func RegisterSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	secrets = append(secrets, secret)
+}
+
+func redact(message string) string {
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+	for _, secret := range secrets {
+		message = strings.ReplaceAll(message, secret, redactedSecret)
+	}
+	return message
+}
+
 // Info : informative messages
 func (l *Logger) Info(message string) {
 	userInterface.Info(message)
@@ -52,9 +90,27 @@ func (l *Logger) Error(message string) {
 	userInterface.Error(fmt.Sprintf(errorColor, message))
 }
 
-func (l *Logger) ErrorAndExit(message string) {
-	userInterface.Error(fmt.Sprintf(errorColor, message))
-	os.Exit(1)
+// SetOutput : write the messages of stdout to another writer, one with a Flush method is flushed before exiting
+func SetOutput(w io.Writer) {
+	basicUi.Writer = w
+	basicUi.ErrorWriter = w
+}
+
+// Flush : write out what the writer set with SetOutput buffers, to be called before exiting
+func Flush() {
+	if flusher, ok := basicUi.Writer.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+}
+
+// Status : progress messages written to stderr so that they never mix with the records written to stdout
+func (l *Logger) Status(message string) {
+	fmt.Fprintln(os.Stderr, message)
+}
+
+// StatusWarn : warning messages written to stderr, like Status
+func (l *Logger) StatusWarn(message string) {
+	fmt.Fprintln(os.Stderr, fmt.Sprintf(warningColor, "[ WARNING ] "+message))
 }
 
 // Output : generic messages
@@ -67,15 +123,35 @@ func (l *Logger) ItalicEmphasize(message string) {
 	userInterface.Output(fmt.Sprintf(italicEmphasize, message))
 }
 
+// Ask : prompt the user for input
+func (l *Logger) Ask(query string) (string, error) {
+	return userInterface.Ask(query)
+}
+
+// AskStatus : prompt the user for input on stderr, like Status
+func (l *Logger) AskStatus(query string) (string, error) {
+	return statusUi.Ask(query)
+}
+
+// AskSecret : prompt the user for input without echoing it
+func (l *Logger) AskSecret(query string) (string, error) {
+	return userInterface.AskSecret(query)
+}
+
 // EnableDebugMode : enable debug mode
 func (l *Logger) EnableDebugMode() {
 	isDebugModeEnabled = true
 	l.Debug("Debug mode is enabled...")
 }
 
+// IsDebugModeEnabled : whether debug messages are shown
+func (l *Logger) IsDebugModeEnabled() bool {
+	return isDebugModeEnabled
+}
+
 // Debug : debugging messages
 func (l *Logger) Debug(message string) {
 	if isDebugModeEnabled {
-		userInterface.Output(fmt.Sprintf("[ DEBUG ] %s", message))
+		userInterface.Output(fmt.Sprintf("[ DEBUG ] %s", redact(message)))
 	}
 }
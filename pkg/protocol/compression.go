@@ -0,0 +1,138 @@
+package protocol
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression codecs, in order of preference
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+)
+
+// SupportedCompressions are the codecs understood by this build, in order of preference
+var SupportedCompressions = []string{CompressionZstd, CompressionGzip}
+
+// NegotiateCompression : first codec of a comma-separated preference list understood by this build, empty for none
+func NegotiateCompression(requested string) string {
+	for _, codec := range strings.Split(requested, ",") {
+		codec = strings.TrimSpace(codec)
+		for _, supported := range SupportedCompressions {
+			if codec == supported {
+				return codec
+			}
+		}
+	}
+	return ""
+}
+
+type compressor interface {
+	io.Writer
+	Flush() error
+}
+
+// CompressedWriter compresses everything written to it, it is safe for concurrent use
+type CompressedWriter struct {
+	mu      sync.Mutex
+	encoder compressor
+	dirty   bool
+}
+
+func NewCompressedWriter(codec string, w io.Writer) (*CompressedWriter, error) {
+	var encoder compressor
+	var err error
+	switch codec {
+	case CompressionZstd:
+		encoder, err = zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	case CompressionGzip:
+		encoder, err = gzip.NewWriterLevel(w, gzip.BestSpeed)
+	default:
+		err = fmt.Errorf("unsupported compression %q", codec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &CompressedWriter{encoder: encoder}, nil
+}
+
+func (c *CompressedWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirty = true
+	return c.encoder.Write(p)
+}
+
+// Flush : make everything written so far decodable by the reader
+func (c *CompressedWriter) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	c.dirty = false
+	return c.encoder.Flush()
+}
+
+// FlushEvery : flush on an interval until the context is done, so that records of a live tail are not held back
+func (c *CompressedWriter) FlushEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = c.Flush()
+		}
+	}
+}
+
+// NewDecompressor : reader decoding a stream compressed with the codec
+func NewDecompressor(codec string, r io.Reader) (io.Reader, error) {
+	switch codec {
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", codec)
+	}
+}
+
+// CountingReader counts the bytes read through it
+type CountingReader struct {
+	reader io.Reader
+	count  int64
+	mu     sync.Mutex
+}
+
+func NewCountingReader(r io.Reader) *CountingReader {
+	return &CountingReader{reader: r}
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.mu.Lock()
+	c.count += int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+// Count : bytes read so far
+func (c *CountingReader) Count() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
@@ -0,0 +1,97 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/dream11/livelogs/models"
+)
+
+// Version is the newest protocol version spoken by this build, MinVersion the oldest one still understood
+const (
+	Version    = 1
+	MinVersion = 1
+)
+
+// FramePrefix marks a protocol frame, so that frames are told apart from text written by the central livelogs agent
+const FramePrefix = "\x1elivelogs-frame "
+
+// Frame types
+const (
+	// FrameHello is the first frame of a session, it carries the negotiated version, 0 when the agent stays in text mode,
+	// and the compression of everything the agent writes after it
+	FrameHello = "hello"
+	// FrameRecord carries a decoded record
+	FrameRecord = "record"
+	// FrameOffsets carries the last offset read from every partition
+	FrameOffsets = "offsets"
+)
+
+// Frame is a single newline delimited JSON message of the protocol
+type Frame struct {
+	Type        string            `json:"type"`
+	Version     int               `json:"version,omitempty"`
+	Agent       string            `json:"agent,omitempty"`
+	Compression string            `json:"compression,omitempty"`
+	Record      *models.LogRecord `json:"record,omitempty"`
+	Offsets     map[int32]int64   `json:"offsets,omitempty"`
+}
+
+// Negotiate : version to speak with a client asking for the requested one, 0 when there is none in common
+func Negotiate(requested int) int {
+	if requested < MinVersion {
+		return 0
+	}
+	if requested > Version {
+		return Version
+	}
+	return requested
+}
+
+// IsSupported : whether this build understands the version
+func IsSupported(version int) bool {
+	return version >= MinVersion && version <= Version
+}
+
+// Writer writes frames, it is safe for concurrent use
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write : encode a frame on its own line
+func (w *Writer) Write(frame Frame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	line := make([]byte, 0, len(FramePrefix)+len(data)+1)
+	line = append(line, FramePrefix...)
+	line = append(line, data...)
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(line)
+	return err
+}
+
+// Parse : frame carried by a line, false when the line is plain text
+func Parse(line string) (*Frame, bool, error) {
+	data, found := strings.CutPrefix(strings.TrimRight(line, "\r\n"), FramePrefix)
+	if !found {
+		return nil, false, nil
+	}
+	frame := &Frame{}
+	if err := json.Unmarshal([]byte(data), frame); err != nil {
+		return nil, true, fmt.Errorf("invalid frame: %w", err)
+	}
+	return frame, true, nil
+}
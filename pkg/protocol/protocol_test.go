@@ -0,0 +1,188 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dream11/livelogs/models"
+)
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	frames := []Frame{
+		{Type: FrameHello, Version: Version, Agent: "1.2.3", Compression: CompressionZstd},
+		{Type: FrameRecord, Record: &models.LogRecord{
+			Topic:     "logs",
+			Partition: 3,
+			Offset:    42,
+			Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			Asg:       &models.AsgLogsStruct{AutoScalingGroupName: "demo", Description: "Launching a new EC2 instance"},
+		}},
+		{Type: FrameOffsets, Offsets: map[int32]int64{0: 10, 7: -1}},
+	}
+
+	var buffer bytes.Buffer
+	writer := NewWriter(&buffer)
+	for _, frame := range frames {
+		if err := writer.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := bufio.NewReader(&buffer)
+	for index, want := range frames {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("frame %d: %v", index, err)
+		}
+		got, isFrame, err := Parse(line)
+		if err != nil || !isFrame {
+			t.Fatalf("frame %d: Parse = %v, %v", index, isFrame, err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("frame %d = %+v, want %+v", index, *got, want)
+		}
+	}
+	if _, err := reader.ReadString('\n'); err != io.EOF {
+		t.Errorf("expected the end of the stream, got %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		isFrame bool
+		wantErr bool
+		want    *Frame
+	}{
+		{name: "text", line: "2024-05-01 10:00:00 INFO started\n"},
+		{name: "empty line", line: "\n"},
+		{name: "prefix not at the start", line: "text " + FramePrefix + `{"type":"hello"}` + "\n"},
+		{name: "frame", line: FramePrefix + `{"type":"hello","version":1}` + "\n", isFrame: true, want: &Frame{Type: FrameHello, Version: 1}},
+		{name: "frame with CRLF", line: FramePrefix + `{"type":"hello","version":1}` + "\r\n", isFrame: true, want: &Frame{Type: FrameHello, Version: 1}},
+		{name: "frame without newline", line: FramePrefix + `{"type":"offsets","offsets":{"2":5}}`, isFrame: true, want: &Frame{Type: FrameOffsets, Offsets: map[int32]int64{2: 5}}},
+		{name: "partial frame", line: FramePrefix + `{"type":"record","record":{"topic":"lo`, isFrame: true, wantErr: true},
+		{name: "prefix only", line: FramePrefix, isFrame: true, wantErr: true},
+		{name: "partial prefix", line: FramePrefix[:5], isFrame: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, isFrame, err := Parse(test.line)
+			if isFrame != test.isFrame {
+				t.Fatalf("isFrame = %v, want %v", isFrame, test.isFrame)
+			}
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("frame = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		requested int
+		want      int
+	}{
+		{0, 0},
+		{-1, 0},
+		{MinVersion, MinVersion},
+		{Version, Version},
+		{Version + 5, Version},
+	}
+	for _, test := range tests {
+		if got := Negotiate(test.requested); got != test.want {
+			t.Errorf("Negotiate(%d) = %d, want %d", test.requested, got, test.want)
+		}
+	}
+	if IsSupported(0) || IsSupported(Version+1) || !IsSupported(Version) {
+		t.Error("IsSupported does not match the versions between MinVersion and Version")
+	}
+}
+
+func TestNegotiateCompression(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"", ""},
+		{"zstd", CompressionZstd},
+		{"gzip", CompressionGzip},
+		{"br, gzip, zstd", CompressionGzip},
+		{"br", ""},
+	}
+	for _, test := range tests {
+		if got := NegotiateCompression(test.requested); got != test.want {
+			t.Errorf("NegotiateCompression(%q) = %q, want %q", test.requested, got, test.want)
+		}
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	for _, codec := range SupportedCompressions {
+		t.Run(codec, func(t *testing.T) {
+			var compressed bytes.Buffer
+			writer, err := NewCompressedWriter(codec, &compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frames := NewWriter(writer)
+			if err := frames.Write(Frame{Type: FrameHello, Version: Version}); err != nil {
+				t.Fatal(err)
+			}
+			if err := frames.Write(Frame{Type: FrameOffsets, Offsets: map[int32]int64{1: 99}}); err != nil {
+				t.Fatal(err)
+			}
+			// A flush makes the frames written so far readable without closing the stream, as a live tail needs
+			if err := writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			decompressor, err := NewDecompressor(codec, NewCountingReader(bytes.NewReader(compressed.Bytes())))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := bufio.NewReader(decompressor)
+			var types []string
+			for len(types) < 2 {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					t.Fatalf("after %v: %v", types, err)
+				}
+				frame, isFrame, err := Parse(line)
+				if err != nil || !isFrame {
+					t.Fatalf("Parse(%q) = %v, %v", line, isFrame, err)
+				}
+				types = append(types, frame.Type)
+			}
+			if strings.Join(types, ",") != FrameHello+","+FrameOffsets {
+				t.Errorf("frames = %v", types)
+			}
+		})
+	}
+
+	if _, err := NewCompressedWriter("br", io.Discard); err == nil {
+		t.Error("expected an error for an unsupported codec")
+	}
+	if _, err := NewDecompressor("br", strings.NewReader("")); err == nil {
+		t.Error("expected an error for an unsupported codec")
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	reader := NewCountingReader(strings.NewReader("hello world"))
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Fatal(err)
+	}
+	if reader.Count() != int64(len("hello world")) {
+		t.Errorf("Count = %d", reader.Count())
+	}
+}
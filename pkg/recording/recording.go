@@ -0,0 +1,283 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A recording starts with the magic, the format version and the metadata as length-prefixed JSON, followed by one
+// length-prefixed frame per record. Integers are varints and byte strings are length-prefixed, nullable ones store
+// their length plus one so that zero marks a nil value.
+const (
+	magic   = "LLREC"
+	Version = 1
+	// maxMetadataSize and maxFrameSize bound the lengths read from a recording, which may come from anyone
+	maxMetadataSize = 1 << 20
+	maxFrameSize    = 64 << 20
+)
+
+// Metadata describes the session that made a recording, so that it is replayed with the same selection
+type Metadata struct {
+	Version       int       `json:"version"`
+	Topic         string    `json:"topic"`
+	ComponentType string    `json:"componentType"`
+	ServiceName   string    `json:"serviceName,omitempty"`
+	ComponentName string    `json:"componentName,omitempty"`
+	AsgName       string    `json:"asgName,omitempty"`
+	IsLowerEnv    bool      `json:"isLowerEnv"`
+	RecordedAt    time.Time `json:"recordedAt"`
+}
+
+// Header is a header of a Kafka record
+type Header struct {
+	Key   []byte
+	Value []byte
+}
+
+// Record is a Kafka record as it was consumed
+type Record struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Key       []byte
+	Headers   []Header
+	Value     []byte
+}
+
+// Writer appends records to a recording, it can be used by several goroutines
+type Writer struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	count  int64
+}
+
+// Create : new recording at path, replacing any existing file
+func Create(path string, metadata Metadata) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Version = Version
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	w := &Writer{file: file, writer: bufio.NewWriter(file)}
+	header := append([]byte(magic), Version)
+	header = binary.AppendUvarint(header, uint64(len(encodedMetadata)))
+	header = append(header, encodedMetadata...)
+	if _, err := w.writer.Write(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write : append a record
+func (w *Writer) Write(record Record) error {
+	var frame []byte
+	frame = appendBytes(frame, []byte(record.Topic))
+	frame = binary.AppendVarint(frame, int64(record.Partition))
+	frame = binary.AppendVarint(frame, record.Offset)
+	frame = binary.AppendVarint(frame, record.Timestamp.UnixNano())
+	frame = appendNullableBytes(frame, record.Key)
+	frame = binary.AppendUvarint(frame, uint64(len(record.Headers)))
+	for _, header := range record.Headers {
+		frame = appendBytes(frame, header.Key)
+		frame = appendNullableBytes(frame, header.Value)
+	}
+	frame = appendNullableBytes(frame, record.Value)
+	if len(frame) > maxFrameSize {
+		return fmt.Errorf("record of %d bytes is larger than the maximum of %d", len(frame), maxFrameSize)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.writer.Write(binary.AppendUvarint(nil, uint64(len(frame)))); err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(frame); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Count : number of records written
+func (w *Writer) Count() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Close : write what is buffered and close the file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.writer.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Reader reads the records of a recording in the order they were written
+type Reader struct {
+	Metadata Metadata
+	file     *os.File
+	reader   *bufio.Reader
+}
+
+// Open : recording at path, its metadata is read straight away
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{file: file, reader: bufio.NewReader(file)}
+	if err := r.readHeader(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reader) readHeader() error {
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(r.reader, header); err != nil || string(header[:len(magic)]) != magic {
+		return errors.New("not a livelogs recording")
+	}
+	if header[len(magic)] != Version {
+		return fmt.Errorf("unsupported recording version %d", header[len(magic)])
+	}
+	length, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return fmt.Errorf("invalid recording metadata: %w", err)
+	}
+	if length > maxMetadataSize {
+		return fmt.Errorf("invalid recording metadata: %d bytes is larger than the maximum of %d", length, maxMetadataSize)
+	}
+	encodedMetadata := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, encodedMetadata); err != nil {
+		return fmt.Errorf("invalid recording metadata: %w", err)
+	}
+	if err := json.Unmarshal(encodedMetadata, &r.Metadata); err != nil {
+		return fmt.Errorf("invalid recording metadata: %w", err)
+	}
+	return nil
+}
+
+// Read : next record, io.EOF once every record is read and io.ErrUnexpectedEOF when the last one is truncated
+func (r *Reader) Read() (*Record, error) {
+	length, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("invalid record frame: %d bytes is larger than the maximum of %d", length, maxFrameSize)
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, frame); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	decoder := &frameDecoder{reader: bytes.NewReader(frame)}
+	record := &Record{
+		Topic:     string(decoder.bytes()),
+		Partition: int32(decoder.varint()),
+		Offset:    decoder.varint(),
+		Timestamp: time.Unix(0, decoder.varint()),
+		Key:       decoder.nullableBytes(),
+	}
+	headerCount := decoder.uvarint()
+	for index := uint64(0); index < headerCount && decoder.err == nil; index++ {
+		record.Headers = append(record.Headers, Header{Key: decoder.bytes(), Value: decoder.nullableBytes()})
+	}
+	record.Value = decoder.nullableBytes()
+	if decoder.err != nil {
+		return nil, fmt.Errorf("invalid record frame: %w", decoder.err)
+	}
+	return record, nil
+}
+
+// Close : close the file
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+func appendBytes(frame, value []byte) []byte {
+	frame = binary.AppendUvarint(frame, uint64(len(value)))
+	return append(frame, value...)
+}
+
+func appendNullableBytes(frame, value []byte) []byte {
+	if value == nil {
+		return binary.AppendUvarint(frame, 0)
+	}
+	frame = binary.AppendUvarint(frame, uint64(len(value))+1)
+	return append(frame, value...)
+}
+
+// frameDecoder reads the fields of a frame, keeping the first error so that it is checked once
+type frameDecoder struct {
+	reader *bytes.Reader
+	err    error
+}
+
+func (d *frameDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	value, err := binary.ReadUvarint(d.reader)
+	d.err = err
+	return value
+}
+
+func (d *frameDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	value, err := binary.ReadVarint(d.reader)
+	d.err = err
+	return value
+}
+
+func (d *frameDecoder) read(length uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if length > uint64(d.reader.Len()) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	value := make([]byte, length)
+	_, d.err = io.ReadFull(d.reader, value)
+	return value
+}
+
+func (d *frameDecoder) bytes() []byte {
+	return d.read(d.uvarint())
+}
+
+func (d *frameDecoder) nullableBytes() []byte {
+	length := d.uvarint()
+	if length == 0 || d.err != nil {
+		return nil
+	}
+	return d.read(length - 1)
+}
@@ -0,0 +1,190 @@
+package recording
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeRecording(t *testing.T, metadata Metadata, records []Record) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.llrec")
+	writer, err := Create(path, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if writer.Count() != int64(len(records)) {
+		t.Errorf("Count = %d, want %d", writer.Count(), len(records))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readAll(t *testing.T, path string) (*Reader, []Record, error) {
+	t.Helper()
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	var records []Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return reader, records, nil
+		}
+		if err != nil {
+			return reader, records, err
+		}
+		records = append(records, *record)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	metadata := Metadata{
+		Topic:         "logs-prod",
+		ComponentType: "application",
+		ServiceName:   "demo-service",
+		ComponentName: "api",
+		RecordedAt:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	records := []Record{
+		{
+			Topic:     "logs-prod",
+			Partition: 2,
+			Offset:    1 << 40,
+			Timestamp: time.Unix(1714557600, 123456789),
+			Key:       []byte("key"),
+			Headers:   []Header{{Key: []byte("trace"), Value: []byte("abc")}, {Key: []byte("nil value")}},
+			Value:     []byte(`{"message":"started"}`),
+		},
+		// Nil and empty keys, header values and values are told apart
+		{Topic: "logs-prod", Partition: 0, Offset: 0, Timestamp: time.Unix(0, 0), Key: []byte{}, Value: []byte{},
+			Headers: []Header{{Key: []byte{}, Value: []byte{}}}},
+		{Topic: "logs-prod", Partition: -1, Offset: -1, Timestamp: time.Unix(-1, 0)},
+	}
+
+	reader, got, err := readAll(t, writeRecording(t, metadata, records))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata.Version = Version
+	if !reader.Metadata.RecordedAt.Equal(metadata.RecordedAt) {
+		t.Errorf("RecordedAt = %v, want %v", reader.Metadata.RecordedAt, metadata.RecordedAt)
+	}
+	reader.Metadata.RecordedAt = metadata.RecordedAt
+	if reader.Metadata != metadata {
+		t.Errorf("Metadata = %+v, want %+v", reader.Metadata, metadata)
+	}
+
+	if len(got) != len(records) {
+		t.Fatalf("read %d records, want %d", len(got), len(records))
+	}
+	for index := range records {
+		if !got[index].Timestamp.Equal(records[index].Timestamp) {
+			t.Errorf("record %d: Timestamp = %v, want %v", index, got[index].Timestamp, records[index].Timestamp)
+		}
+		got[index].Timestamp = records[index].Timestamp
+		if !reflect.DeepEqual(got[index], records[index]) {
+			t.Errorf("record %d = %#v, want %#v", index, got[index], records[index])
+		}
+	}
+
+	if got[1].Key == nil || got[1].Value == nil || got[1].Headers[0].Value == nil {
+		t.Error("empty key, value or header value read back as nil")
+	}
+	if got[2].Key != nil || got[2].Value != nil || got[0].Headers[1].Value != nil {
+		t.Error("nil key, value or header value read back as empty")
+	}
+}
+
+func TestTruncatedLastFrame(t *testing.T) {
+	records := []Record{
+		{Topic: "logs", Offset: 1, Value: []byte("first")},
+		{Topic: "logs", Offset: 2, Value: []byte("second")},
+	}
+	path := writeRecording(t, Metadata{Topic: "logs"}, records)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cutting the end or the middle of the last frame
+	for _, cut := range []int64{1, 5} {
+		if err := os.Truncate(path, info.Size()-cut); err != nil {
+			t.Fatal(err)
+		}
+		_, got, err := readAll(t, path)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("cut %d: err = %v, want io.ErrUnexpectedEOF", cut, err)
+		}
+		if len(got) != 1 || string(got[0].Value) != "first" {
+			t.Errorf("cut %d: records before the truncated one = %+v", cut, got)
+		}
+	}
+}
+
+func TestOversizedLengths(t *testing.T) {
+	directory := t.TempDir()
+
+	metadataPath := filepath.Join(directory, "metadata.llrec")
+	header := binary.AppendUvarint(append([]byte(magic), Version), maxMetadataSize+1)
+	if err := os.WriteFile(metadataPath, header, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(metadataPath); err == nil || !strings.Contains(err.Error(), "larger than the maximum") {
+		t.Errorf("Open with oversized metadata = %v", err)
+	}
+
+	framePath := writeRecording(t, Metadata{Topic: "logs"}, nil)
+	file, err := os.OpenFile(framePath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(binary.AppendUvarint(nil, maxFrameSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	if _, _, err := readAll(t, framePath); err == nil || !strings.Contains(err.Error(), "larger than the maximum") {
+		t.Errorf("Read of an oversized frame = %v", err)
+	}
+}
+
+func TestInvalidHeader(t *testing.T) {
+	directory := t.TempDir()
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"empty", nil, "not a livelogs recording"},
+		{"other file", []byte("PK\x03\x04 zip"), "not a livelogs recording"},
+		{"newer version", append([]byte(magic), Version+1), "unsupported recording version"},
+		{"truncated metadata", binary.AppendUvarint(append([]byte(magic), Version), 10), "invalid recording metadata"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(directory, test.name)
+			if err := os.WriteFile(path, test.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Open(path); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Open = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,13 @@ package request
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/dream11/livelogs/pkg/logger"
@@ -18,12 +22,15 @@ type Request struct {
 	Query   map[string]string
 	Body    interface{}
 	Timeout time.Duration
+	// Transport sends the request, nil for the default one
+	Transport http.RoundTripper
 }
 
 // Response structure
 type Response struct {
 	Status     string
 	StatusCode int
+	Header     http.Header
 	Body       []byte
 	Error      error
 }
@@ -32,6 +39,24 @@ const defaultRequestTimeOut = 5 * time.Second
 
 var log logger.Logger
 
+// NewTransport : transport trusting the certificates of a PEM bundle, on top of the system ones, for HTTPS requests
+func NewTransport(caBundle string) (http.RoundTripper, error) {
+	bundle, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no certificates found in %s", caBundle)
+	}
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+	httpTransport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	return httpTransport, nil
+}
+
 // Make : make a generated request
 func (r *Request) Make() Response {
 	payload := new(bytes.Buffer)
@@ -64,7 +89,7 @@ func (r *Request) Make() Response {
 	if r.Timeout == 0 {
 		r.Timeout = defaultRequestTimeOut
 	}
-	response, err := (&http.Client{Timeout: r.Timeout}).Do(request)
+	response, err := (&http.Client{Timeout: r.Timeout, Transport: r.Transport}).Do(request)
 
 	if err != nil {
 		return Response{Error: err}
@@ -79,6 +104,7 @@ func (r *Request) Make() Response {
 	return Response{
 		Status:     response.Status,
 		StatusCode: response.StatusCode,
+		Header:     response.Header,
 		Body:       respBody,
 		Error:      nil,
 	}
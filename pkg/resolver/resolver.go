@@ -0,0 +1,131 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDnsPort = "53"
+
+// Resolver resolves host names with the Go resolver, optionally against a specific DNS server, caching answers briefly
+type Resolver struct {
+	resolver *net.Resolver
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	addresses []string
+	expiresAt time.Time
+}
+
+// New : resolver using the DNS server at host[:port], the system configuration when empty, caching answers for ttl
+func New(dnsServer string, ttl time.Duration) *Resolver {
+	resolver := net.DefaultResolver
+	if dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(dnsServer, defaultDnsPort)
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, dnsServer)
+			},
+		}
+	}
+	return &Resolver{resolver: resolver, ttl: ttl, cache: map[string]cacheEntry{}}
+}
+
+// LookupIPs : IPv4 and IPv6 addresses of a host, following CNAME chains, an IP literal resolves to itself
+func (r *Resolver) LookupIPs(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, nil
+	}
+	return r.cached("ip/"+host, func() ([]string, error) {
+		ipAddrs, err := r.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ips := make([]string, 0, len(ipAddrs))
+		for _, ipAddr := range ipAddrs {
+			ips = append(ips, ipAddr.IP.String())
+		}
+		return ips, nil
+	})
+}
+
+// LookupSRV : host:port addresses of the targets of an SRV record such as _kafka._tcp.example.com, in priority order
+func (r *Resolver) LookupSRV(ctx context.Context, name string) ([]string, error) {
+	return r.cached("srv/"+name, func() ([]string, error) {
+		_, records, err := r.resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		var addresses []string
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			ips, err := r.LookupIPs(ctx, target)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve SRV target %s: %w", target, err)
+			}
+			for _, ip := range ips {
+				addresses = append(addresses, net.JoinHostPort(ip, strconv.Itoa(int(record.Port))))
+			}
+		}
+		return addresses, nil
+	})
+}
+
+// LookupAddresses : host:port addresses of a service, found through SRV when the name is an SRV name
+// (starting with an underscore), otherwise the host IPs with the port of the name or defaultPort
+func (r *Resolver) LookupAddresses(ctx context.Context, name, defaultPort string) ([]string, error) {
+	if IsSrvName(name) {
+		return r.LookupSRV(ctx, name)
+	}
+	host, port, err := net.SplitHostPort(name)
+	if err != nil {
+		host, port = name, defaultPort
+	}
+	ips, err := r.LookupIPs(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addresses = append(addresses, net.JoinHostPort(ip, port))
+	}
+	return addresses, nil
+}
+
+// IsSrvName : whether a name is the name of an SRV record
+func IsSrvName(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
+
+func (r *Resolver) cached(key string, lookup func() ([]string, error)) ([]string, error) {
+	r.mu.Lock()
+	entry, found := r.cache[key]
+	r.mu.Unlock()
+	if found && time.Now().Before(entry.expiresAt) {
+		return append([]string(nil), entry.addresses...), nil
+	}
+
+	addresses, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[key] = cacheEntry{addresses: addresses, expiresAt: time.Now().Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return append([]string(nil), addresses...), nil
+}
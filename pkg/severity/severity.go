@@ -0,0 +1,166 @@
+package severity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Level is the normalized severity of a log record, higher is more severe
+type Level int
+
+const (
+	Unknown Level = iota
+	Trace
+	Debug
+	Info
+	Warn
+	Error
+	Fatal
+)
+
+var levelNames = map[Level]string{
+	Unknown: "unknown",
+	Trace:   "trace",
+	Debug:   "debug",
+	Info:    "info",
+	Warn:    "warn",
+	Error:   "error",
+	Fatal:   "fatal",
+}
+
+// aliases maps the status strings commonly set by producers to a level
+var aliases = map[string]Level{
+	"trace":       Trace,
+	"debug":       Debug,
+	"dbg":         Debug,
+	"info":        Info,
+	"information": Info,
+	"notice":      Info,
+	"ok":          Info,
+	"warn":        Warn,
+	"warning":     Warn,
+	"error":       Error,
+	"err":         Error,
+	"fatal":       Fatal,
+	"critical":    Fatal,
+	"crit":        Fatal,
+	"alert":       Fatal,
+	"emerg":       Fatal,
+	"emergency":   Fatal,
+	"panic":       Fatal,
+}
+
+// syslogLevels maps numeric syslog severities to a level
+var syslogLevels = []Level{Fatal, Fatal, Fatal, Error, Warn, Info, Info, Debug}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return levelNames[Unknown]
+}
+
+// Parse : normalize a producer status or a level name, numeric syslog severities are accepted too
+func Parse(status string) (Level, bool) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if level, ok := aliases[status]; ok {
+		return level, true
+	}
+	if number, err := strconv.Atoi(status); err == nil && number >= 0 && number < len(syslogLevels) {
+		return syslogLevels[number], true
+	}
+	return Unknown, false
+}
+
+// Mapper resolves the level of a record from its status, using custom mappings before the built-in aliases
+type Mapper struct {
+	mapping map[string]Level
+}
+
+// NewMapper : create a mapper from a comma separated list of status=level pairs, example "sev1=fatal,notice=warn"
+func NewMapper(mapping string) (*Mapper, error) {
+	mapper := &Mapper{mapping: map[string]Level{}}
+	for _, pair := range splitList(mapping) {
+		status, levelName, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid level mapping %q, expected status=level", pair)
+		}
+		level, ok := Parse(levelName)
+		if !ok {
+			return nil, fmt.Errorf("invalid level %q in level mapping %q", levelName, pair)
+		}
+		mapper.mapping[strings.ToLower(strings.TrimSpace(status))] = level
+	}
+	return mapper, nil
+}
+
+// Resolve : level of a record, falling back to looking for error and warning words in the message when the status is unknown
+func (m *Mapper) Resolve(status, message string) Level {
+	if level, ok := m.mapping[strings.ToLower(strings.TrimSpace(status))]; ok {
+		return level
+	}
+	if level, ok := Parse(status); ok {
+		return level
+	}
+
+	lowerMessage := strings.ToLower(message)
+	switch {
+	case strings.Contains(lowerMessage, "error"):
+		return Error
+	case strings.Contains(lowerMessage, "warn"):
+		return Warn
+	default:
+		return Info
+	}
+}
+
+// Selector decides which levels are shown
+type Selector struct {
+	minimum Level
+	levels  map[Level]bool
+}
+
+// NewSelector : a single level selects it and everything more severe, a comma separated list selects exactly those levels
+func NewSelector(value string) (*Selector, error) {
+	names := splitList(value)
+	if len(names) == 0 {
+		return &Selector{}, nil
+	}
+
+	if len(names) == 1 {
+		level, ok := Parse(names[0])
+		if !ok {
+			return nil, fmt.Errorf("invalid level %q", names[0])
+		}
+		return &Selector{minimum: level}, nil
+	}
+
+	selector := &Selector{levels: map[Level]bool{}}
+	for _, name := range names {
+		level, ok := Parse(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid level %q", name)
+		}
+		selector.levels[level] = true
+	}
+	return selector, nil
+}
+
+// Matches : true when records of the given level should be shown
+func (s *Selector) Matches(level Level) bool {
+	if s.levels != nil {
+		return s.levels[level]
+	}
+	return level >= s.minimum
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
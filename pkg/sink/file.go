@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/formatter"
+)
+
+// fileSink appends records to a file, rotated to <path>.1 … <path>.<maxFiles> once it reaches maxSize bytes.
+// A zero maxSize never rotates.
+type fileSink struct {
+	path      string
+	maxSize   int64
+	maxFiles  int
+	formatter *formatter.Formatter
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func newFileSink(path string, maxSize int64, maxFiles int, recordFormatter *formatter.Formatter) (*fileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	sink := &fileSink{path: path, maxSize: maxSize, maxFiles: maxFiles, formatter: recordFormatter}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (f *fileSink) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *fileSink) Write(record *models.LogRecord) error {
+	text, err := render(f.formatter, record)
+	if err != nil {
+		return err
+	}
+	line := []byte(text + "\n")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return fmt.Errorf("file sink %s is closed", f.path)
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+		}
+	}
+	written, err := f.file.Write(line)
+	f.size += int64(written)
+	return err
+}
+
+// rotate : shift the rotated files by one, dropping the oldest, and start a new file
+func (f *fileSink) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if f.maxFiles == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return f.open()
+	}
+
+	for index := f.maxFiles - 1; index >= 1; index-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", f.path, index), fmt.Sprintf("%s.%d", f.path, index+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *fileSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,113 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/formatter"
+)
+
+// httpSink POSTs records in NDJSON batches, sent once a batch is full or every flush interval. Records are queued so
+// that a slow endpoint does not hold the session back, they are dropped once the queue is full.
+type httpSink struct {
+	url       string
+	client    *http.Client
+	formatter *formatter.Formatter
+	lines     chan string
+	done      chan struct{}
+}
+
+func newHttpSink(url string, recordFormatter *formatter.Formatter) *httpSink {
+	sink := &httpSink{
+		url:       url,
+		client:    &http.Client{Timeout: constants.SinkHttpTimeout},
+		formatter: recordFormatter,
+		lines:     make(chan string, constants.SinkQueueSize),
+		done:      make(chan struct{}),
+	}
+	go sink.run()
+	return sink
+}
+
+func (h *httpSink) Write(record *models.LogRecord) error {
+	text, err := render(h.formatter, record)
+	if err != nil {
+		return err
+	}
+	select {
+	case h.lines <- text:
+		return nil
+	default:
+		return fmt.Errorf("queue of %s is full, record dropped", h.url)
+	}
+}
+
+func (h *httpSink) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(constants.SinkFlushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	count := 0
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		if err := h.post(batch.Bytes()); err != nil {
+			log.Warn(fmt.Sprintf("Failed to send %d records to %s: %v", count, h.url, err))
+		}
+		batch.Reset()
+		count = 0
+	}
+
+	for {
+		select {
+		case line, ok := <-h.lines:
+			if !ok {
+				flush()
+				return
+			}
+			batch.WriteString(line)
+			batch.WriteByte('\n')
+			count++
+			if count >= constants.SinkBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (h *httpSink) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.SinkHttpTimeout)
+	defer cancel()
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-ndjson")
+	response, err := h.client.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+// Close : send the queued records
+func (h *httpSink) Close() error {
+	close(h.lines)
+	<-h.done
+	return nil
+}
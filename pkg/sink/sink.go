@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/formatter"
+	"github.com/dream11/livelogs/pkg/logger"
+	"github.com/dream11/livelogs/pkg/severity"
+)
+
+var log logger.Logger
+
+// Sink receives the records shown by a session
+type Sink interface {
+	// Write : deliver a record, sinks that can not keep up drop it and return an error
+	Write(record *models.LogRecord) error
+	// Close : deliver what is still buffered and release the sink
+	Close() error
+}
+
+// Open : sink described by a --sink value. The terminal renders records with the formatter of the session, the
+// other sinks write NDJSON unless a format option says otherwise.
+//
+//	terminal
+//	file:<path>[?max_size=100MB&max_files=5&format=ndjson]
+//	unix:<path>[?format=ndjson]
+//	tcp:<host:port>[?format=ndjson]
+//	http(s)://<url>
+func Open(spec string, terminalFormatter *formatter.Formatter) (Sink, error) {
+	if spec == constants.SinkTerminal {
+		return &terminalSink{formatter: terminalFormatter}, nil
+	}
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		if _, err := url.ParseRequestURI(spec); err != nil {
+			return nil, fmt.Errorf("invalid sink %q: %w", spec, err)
+		}
+		recordFormatter, _ := formatter.New(formatter.Options{Output: formatter.OutputNDJSON})
+		return newHttpSink(spec, recordFormatter), nil
+	}
+
+	kind, target, found := strings.Cut(spec, ":")
+	if !found || target == "" {
+		return nil, invalidSinkError(spec)
+	}
+	target, rawOptions, _ := strings.Cut(target, "?")
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid options of sink %q: %w", spec, err)
+	}
+	format := options.Get("format")
+	if format == "" {
+		format = formatter.OutputNDJSON
+	}
+	recordFormatter, err := formatter.New(formatter.Options{Output: format})
+	if err != nil {
+		return nil, fmt.Errorf("invalid format of sink %q: %w", spec, err)
+	}
+
+	switch kind {
+	case "file":
+		maxSize := int64(constants.DefaultSinkMaxFileSize)
+		if value := options.Get("max_size"); value != "" {
+			if maxSize, err = parseSize(value); err != nil {
+				return nil, fmt.Errorf("invalid max_size of sink %q: %w", spec, err)
+			}
+		}
+		maxFiles := constants.DefaultSinkMaxFiles
+		if value := options.Get("max_files"); value != "" {
+			if maxFiles, err = strconv.Atoi(value); err != nil || maxFiles < 0 {
+				return nil, fmt.Errorf("invalid max_files of sink %q, must be a positive number", spec)
+			}
+		}
+		return newFileSink(target, maxSize, maxFiles, recordFormatter)
+	case "unix", "tcp":
+		return newSocketSink(kind, target, recordFormatter), nil
+	}
+	return nil, invalidSinkError(spec)
+}
+
+func invalidSinkError(spec string) error {
+	return fmt.Errorf("invalid sink %q, must be one of: %s, file:<path>, unix:<path>, tcp:<host:port>, http(s)://<url>", spec, constants.SinkTerminal)
+}
+
+// render : a record as a single line of the format of a sink
+func render(recordFormatter *formatter.Formatter, record *models.LogRecord) (string, error) {
+	if record.Asg != nil {
+		return recordFormatter.FormatAsgLog(*record.Asg)
+	}
+	if record.Application != nil {
+		return recordFormatter.FormatApplicationLog(*record.Application)
+	}
+	// A line that was already rendered, by an agent that only sends text, is kept as the message of a record
+	if record.Text != "" {
+		message := strings.TrimRight(record.Text, "\r\n")
+		if recordFormatter.IsText() {
+			return message, nil
+		}
+		encoded, err := json.Marshal(map[string]string{"message": message})
+		return string(encoded), err
+	}
+	return "", fmt.Errorf("empty record")
+}
+
+// terminalSink prints records on stdout, text records are coloured by level
+type terminalSink struct {
+	formatter *formatter.Formatter
+}
+
+func (t *terminalSink) Write(record *models.LogRecord) error {
+	// Lines of a central livelogs agent that only sends text were rendered by the agent
+	if record.Text != "" {
+		fmt.Print(record.Text)
+		return nil
+	}
+	text, err := render(t.formatter, record)
+	if err != nil {
+		return err
+	}
+	if record.Application == nil || !t.formatter.IsText() {
+		log.Output(text)
+		return nil
+	}
+
+	level, _ := severity.Parse(record.Application.Level)
+	switch {
+	case level >= severity.Error:
+		log.Error(text)
+	case level == severity.Warn:
+		log.Warn(text)
+	default:
+		log.Info(text)
+	}
+	return nil
+}
+
+func (t *terminalSink) Close() error {
+	return nil
+}
+
+// parseSize : number of bytes of a size such as 512KB, 100MB or 1GB
+func parseSize(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			value, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("%q is not a size such as 100MB", value)
+	}
+	return size * multiplier, nil
+}
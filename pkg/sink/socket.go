@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/formatter"
+)
+
+// socketSink writes records as lines to a unix or tcp socket. The connection is dialed on the first record and
+// re-dialed after a failure, records are dropped while the socket can not be reached.
+type socketSink struct {
+	network   string
+	address   string
+	formatter *formatter.Formatter
+
+	mu      sync.Mutex
+	conn    net.Conn
+	retryAt time.Time
+}
+
+func newSocketSink(network, address string, recordFormatter *formatter.Formatter) *socketSink {
+	return &socketSink{network: network, address: address, formatter: recordFormatter}
+}
+
+func (s *socketSink) Write(record *models.LogRecord) error {
+	text, err := render(s.formatter, record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if time.Now().Before(s.retryAt) {
+			return fmt.Errorf("%s socket %s is unreachable, record dropped", s.network, s.address)
+		}
+		conn, err := net.DialTimeout(s.network, s.address, constants.SinkDialTimeout)
+		if err != nil {
+			s.retryAt = time.Now().Add(constants.SinkRetryInterval)
+			return fmt.Errorf("failed to connect to %s socket %s: %w", s.network, s.address, err)
+		}
+		s.conn = conn
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(constants.SinkWriteTimeout))
+	if _, err := s.conn.Write([]byte(text + "\n")); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		s.retryAt = time.Now().Add(constants.SinkRetryInterval)
+		return fmt.Errorf("failed to write to %s socket %s: %w", s.network, s.address, err)
+	}
+	return nil
+}
+
+func (s *socketSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
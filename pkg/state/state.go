@@ -0,0 +1,85 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Offsets maps a partition to the last offset processed from it
+type Offsets map[int32]int64
+
+// State is the persisted position of a resumable tail
+type State struct {
+	Topics    map[string]Offsets `json:"topics"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Path : location of the state file of a named tail inside the livelogs directory
+func Path(livelogsDir, name string) (string, error) {
+	if !validName.MatchString(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid resume name %q, use letters, digits, '.', '_' and '-'", name)
+	}
+	return filepath.Join(livelogsDir, "state", name+".json"), nil
+}
+
+// Load : read a state file, a missing file is an empty state
+func Load(path string) (*State, error) {
+	state := &State{Topics: map[string]Offsets{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("corrupted state file %s: %w", path, err)
+	}
+	if state.Topics == nil {
+		state.Topics = map[string]Offsets{}
+	}
+	return state, nil
+}
+
+// Update : record the last processed offsets of a topic, partitions that are not part of offsets are kept
+func (s *State) Update(topic string, offsets Offsets) {
+	if s.Topics[topic] == nil {
+		s.Topics[topic] = Offsets{}
+	}
+	for partition, offset := range offsets {
+		s.Topics[topic][partition] = offset
+	}
+}
+
+// NextOffsets : offset to resume each partition of a topic from
+func (s *State) NextOffsets(topic string) Offsets {
+	next := Offsets{}
+	for partition, offset := range s.Topics[topic] {
+		next[partition] = offset + 1
+	}
+	return next
+}
+
+// Save : write the state atomically so that an interrupted save never corrupts it
+func (s *State) Save(path string) error {
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
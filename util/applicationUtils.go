@@ -1,60 +1,80 @@
 package util
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
+	"net/http"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dream11/livelogs/constants"
 	"github.com/dream11/livelogs/models"
+	"github.com/dream11/livelogs/pkg/configcache"
 	"github.com/dream11/livelogs/pkg/logger"
 	"github.com/dream11/livelogs/pkg/request"
+	"github.com/dream11/livelogs/pkg/resolver"
 )
 
 var log logger.Logger
 
-func getLogsSearchConfig(env, org, account, cloudProvider, serviceName, componentName, componentType, asgName string) models.LogSearchConfig {
+var (
+	// ErrOrchestratorUnavailable : the orchestrator can not answer, a cached config can stand in for it
+	ErrOrchestratorUnavailable = errors.New("livelogs orchestrator is unavailable")
+	// ErrNotAuthorized : the orchestrator refused the token, or there is none
+	ErrNotAuthorized = errors.New("not authorized")
+)
+
+// noStore is the cache ttl of a config that must not be written to disk
+const noStore = time.Duration(-1)
+
+// fetchLogsSearchConfig : config of a query from the orchestrator, with how long it may be cached, noStore when it may not
+func (o *Orchestrator) fetchLogsSearchConfig(key configcache.Key) (models.LogSearchConfig, time.Duration, error) {
 	queryMap := map[string]string{
-		"serviceName":   serviceName,
-		"componentName": componentName,
-		"componentType": componentType,
-		"env":           env,
-		"org":           org,
-		"account":       account,
-		"cloudProvider": cloudProvider,
-		"asgName":       asgName,
+		"serviceName":   key.ServiceName,
+		"componentName": key.ComponentName,
+		"componentType": key.ComponentType,
+		"env":           key.Env,
+		"org":           key.Org,
+		"account":       key.Account,
+		"cloudProvider": key.CloudProvider,
+		"asgName":       key.AsgName,
 	}
 	log.Debug(fmt.Sprintf("Fetching logs search config with query: %v", queryMap))
 
 	req := request.Request{
-		Method: "GET",
-		Header: map[string]string{"Content-Type": "application/json"},
-		URL:    constants.CentralLiveLogAgentHost + "/api/v1/livelogs/config",
-		Query:  queryMap,
+		Method:    "GET",
+		Header:    o.header(map[string]string{"Content-Type": "application/json"}),
+		URL:       o.url + "/api/v1/livelogs/config",
+		Query:     queryMap,
+		Transport: o.transport,
 	}
 	res := req.Make()
 	if res.Error != nil {
-		log.Debug("Error making http request to fetch log search config" + res.Error.Error())
-		log.ErrorAndExit("Error in fetching log search config. Please connect to vpn and try again.")
+		return models.LogSearchConfig{}, 0, fmt.Errorf("%w, error in fetching log search config: %v", ErrOrchestratorUnavailable, res.Error)
 	}
 
+	if res.StatusCode == 401 || res.StatusCode == 403 {
+		return models.LogSearchConfig{}, 0, fmt.Errorf("%w to fetch log search config from %s", ErrNotAuthorized, o.url)
+	}
 	if res.StatusCode != 200 {
 		var errorBody struct {
 			Error struct {
 				Message string `json:"message"`
 			} `json:"error"`
 		}
-		err := json.Unmarshal(res.Body, &errorBody)
-		if err != nil {
-			log.ErrorAndExit("Error in fetching log search config: " + string(res.Body))
+		message := string(res.Body)
+		if err := json.Unmarshal(res.Body, &errorBody); err == nil {
+			message = errorBody.Error.Message
 		}
-		log.ErrorAndExit("Error in fetching log search config: " + errorBody.Error.Message)
+		if res.StatusCode >= 500 {
+			return models.LogSearchConfig{}, 0, fmt.Errorf("%w, error in fetching log search config: %s", ErrOrchestratorUnavailable, message)
+		}
+		return models.LogSearchConfig{}, 0, errors.New("Error in fetching log search config: " + message)
 	}
 
 	var responseBody struct {
@@ -63,95 +83,116 @@ func getLogsSearchConfig(env, org, account, cloudProvider, serviceName, componen
 
 	err := json.Unmarshal(res.Body, &responseBody)
 	if err != nil {
-		log.ErrorAndExit("Error in fetching log search config: " + err.Error())
+		return models.LogSearchConfig{}, 0, errors.New("Error in fetching log search config: " + err.Error())
 	}
 
+	RegisterLogSearchConfigSecrets(responseBody.Data)
 	log.Debug(fmt.Sprintf("Fetched logs search config: %v", responseBody.Data))
-	return responseBody.Data
+	return responseBody.Data, getCacheTtl(res.Header), nil
 }
 
-func GetUtcTimeDuration(timeStamp string) time.Duration {
-	const timestampLayout = "2006-01-02 15:04:05"
-	parsedTime, err := time.Parse(timestampLayout, timeStamp)
-	if err != nil {
-		log.ErrorAndExit(fmt.Sprintf("Error parsing timestamp: %s", timeStamp))
+// getCacheTtl : max-age advertised by the orchestrator in Cache-Control, the default when there is none. A no-cache
+// config is stored to stand in for an unreachable orchestrator but never used otherwise, a no-store one is not stored.
+func getCacheTtl(header http.Header) time.Duration {
+	ttl := constants.LogSearchConfigCacheTtl
+	noCache := false
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return noStore
+		case directive == "no-cache":
+			noCache = true
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && seconds >= 0 {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
 	}
-	return time.Since(parsedTime.Add(-time.Minute * 330))
+	if noCache {
+		return 0
+	}
+	return ttl
 }
 
-func GetEpochTimeFromTimestamp(timeStamp string) int64 {
-	timestampLayout := "2006-01-02 15:04:05"
-	parsedTime, err := time.Parse(timestampLayout, timeStamp)
+func openConfigCache() *configcache.Cache {
+	livelogsDir, err := GetLivelogsDir()
+	if err != nil {
+		log.Debug("Unable to access livelogs directory, log search configs are not cached: " + err.Error())
+		return nil
+	}
+	cache, err := configcache.Open(livelogsDir)
 	if err != nil {
-		log.ErrorAndExit(fmt.Sprintf("Error parsing timestamp: %s", timeStamp))
+		log.Debug("Unable to open log search config cache: " + err.Error())
+		return nil
 	}
-	return parsedTime.Add(-time.Minute*330).UnixNano() / int64(time.Millisecond)
+	return cache
 }
 
-func GetLogsSearchConfig(env, org, account, cloudProvider, serviceName, componentName, componentType, asgName string) models.LogSearchConfig {
+// GetLogsSearchConfig : config of a query from the orchestrator, a cached one when it is fresh or the orchestrator is unreachable
+func (o *Orchestrator) GetLogsSearchConfig(env, org, account, cloudProvider, serviceName, componentName, componentType, asgName string) (models.LogSearchConfig, error) {
 	if account == "" && (env == "prod" || org == "uat") {
 		account = "prod"
 	}
-	return getLogsSearchConfig(env, org, account, cloudProvider, serviceName, componentName, componentType, asgName)
-}
+	key := configcache.Key{Orchestrator: o.url, Env: env, Org: org, Account: account, CloudProvider: cloudProvider, ServiceName: serviceName, ComponentName: componentName, ComponentType: componentType, AsgName: asgName}
 
-func GetIpsFromHost(host string) []string {
-	log.Debug("Resolving DNS of logs-agent host: " + host)
-	command := exec.Command("dig", host, "+short")
-	var out bytes.Buffer
-	command.Stdout = &out
+	cache := openConfigCache()
+	var cached *configcache.Entry
+	if cache != nil {
+		var err error
+		if cached, err = cache.Get(key); err != nil {
+			log.Debug("Ignoring cached log search config: " + err.Error())
+		}
+	}
+	if cached != nil && cached.IsFresh(time.Now()) {
+		log.Debug("Using log search config cached at " + cached.FetchedAt.Format(time.RFC3339))
+		RegisterLogSearchConfigSecrets(cached.Config)
+		return cached.Config, nil
+	}
 
-	err := command.Run()
+	config, ttl, err := o.fetchLogsSearchConfig(key)
 	if err != nil {
-		log.ErrorAndExit("Error in connecting to host: " + host + " Error:" + err.Error())
+		if cached != nil && errors.Is(err, ErrOrchestratorUnavailable) {
+			RegisterLogSearchConfigSecrets(cached.Config)
+			log.Debug(err.Error())
+			o.warn("Livelogs orchestrator is unreachable, using the log search config cached at " + cached.FetchedAt.Format(time.RFC3339) + ", it may be out of date")
+			return cached.Config, nil
+		}
+		return models.LogSearchConfig{}, err
 	}
-
-	scanner := bufio.NewScanner(&out)
-	var ips []string
-	for scanner.Scan() {
-		ip := scanner.Text()
-		if isValidIP(ip) {
-			ips = append(ips, ip)
+	switch {
+	case cache == nil:
+	case ttl == noStore:
+		if err := cache.Delete(key); err != nil {
+			log.Debug("Failed to remove cached log search config: " + err.Error())
+		}
+	default:
+		if err := cache.Put(key, config, ttl); err != nil {
+			log.Debug("Failed to cache log search config: " + err.Error())
 		}
 	}
-	return ips
+	return config, nil
 }
 
-func GetAnyRandomIpFromHost(host string) string {
-	ips := GetIpsFromHost(host)
-	selectedIP := ""
-	if len(ips) > 0 {
-		selectedIP = ips[rand.Intn(len(ips))]
-	}
-	return selectedIP
+// GetIpsFromHost : IPv4 and IPv6 addresses of a host
+func GetIpsFromHost(dnsResolver *resolver.Resolver, host string) ([]string, error) {
+	log.Debug("Resolving DNS of host: " + host)
+	ctx, cancel := context.WithTimeout(context.Background(), constants.DnsLookupTimeout)
+	defer cancel()
+	return dnsResolver.LookupIPs(ctx, host)
 }
 
-func isValidIP(ip string) bool {
-	parts := strings.Split(ip, ".")
-	if len(parts) != 4 {
-		return false
-	}
-	for _, part := range parts {
-		if len(part) == 0 {
-			return false
-		}
-		for _, digit := range part {
-			if digit < '0' || digit > '9' {
-				return false
-			}
-		}
-		num := 0
-		for _, digit := range part {
-			num = num*10 + int(digit-'0')
-		}
-		if num < 0 || num > 255 {
-			return false
-		}
-	}
-	return true
+// GetAddressesFromHost : host:port addresses of a service, through SRV records when the name is an SRV name
+func GetAddressesFromHost(dnsResolver *resolver.Resolver, name, defaultPort string) ([]string, error) {
+	log.Debug("Resolving DNS of service: " + name)
+	ctx, cancel := context.WithTimeout(context.Background(), constants.DnsLookupTimeout)
+	defer cancel()
+	return dnsResolver.LookupAddresses(ctx, name, defaultPort)
 }
 
-func UserLogFunc(args *models.LogsCommandArgs, tenant string) {
+// UserLogFunc : record the session of a user with the orchestrator
+func (o *Orchestrator) UserLogFunc(args *models.LogsCommandArgs, tenant string) {
 	hostName := os.Getenv(constants.EnvLivelogsUser)
 	if len(hostName) == 0 {
 		hostName, _ = os.Hostname()
@@ -176,15 +217,16 @@ func UserLogFunc(args *models.LogsCommandArgs, tenant string) {
 
 	req := request.Request{
 		Method: "POST",
-		URL:    constants.CentralLiveLogAgentHost + "/api/v1/livelogs/log",
-		Header: map[string]string{
+		URL:    o.url + "/api/v1/livelogs/log",
+		Header: o.header(map[string]string{
 			"X-Tenant-Name": tenant,
 			"Content-Type":  "application/json",
-		},
+		}),
 		Body: models.UserLogStruct{
 			Hostname: hostName,
 			Command:  string(commandMarshal),
 		},
+		Transport: o.transport,
 	}
 
 	log.Debug("Logging user session for command: " + string(commandMarshal))
@@ -198,33 +240,67 @@ func UserLogFunc(args *models.LogsCommandArgs, tenant string) {
 	}
 }
 
-func isAwsMachine() bool {
+// getAwsMetadataToken : IMDSv2 session token, empty when the instance only serves IMDSv1 or is not on aws
+func getAwsMetadataToken() string {
+	req := request.Request{
+		Method:  "PUT",
+		URL:     constants.AwsMetadataTokenUrl,
+		Timeout: constants.MetadataProbeTimeout,
+		Header: map[string]string{
+			"X-aws-ec2-metadata-token-ttl-seconds": constants.AwsMetadataTokenTtlSeconds,
+		},
+	}
+
+	res := req.Make()
+	if res.Error != nil {
+		log.Debug("Error making http request to fetch aws metadata token: " + res.Error.Error())
+		return ""
+	}
+	if res.StatusCode != 200 {
+		log.Debug("Invalid status code while fetching aws metadata token: " + fmt.Sprint(res.StatusCode))
+		return ""
+	}
+	return string(res.Body)
+}
+
+// isAwsMachine : whether the aws metadata endpoint answers, an error when it could not be asked
+func isAwsMachine() (bool, error) {
+	header := map[string]string{}
+	if token := getAwsMetadataToken(); token != "" {
+		header["X-aws-ec2-metadata-token"] = token
+	}
 	req := request.Request{
 		Method:  "GET",
 		URL:     constants.AwsMetadataUrl,
-		Timeout: 2 * time.Second,
+		Timeout: constants.MetadataProbeTimeout,
+		Header:  header,
 	}
 
 	res := req.Make()
 	if res.Error != nil {
 		log.Debug("Error making http request to fetch aws metadata: " + res.Error.Error())
-		return false
+		return false, res.Error
+	}
+	if res.StatusCode >= 500 {
+		log.Debug("Invalid aws metadata response: " + string(res.Body))
+		return false, fmt.Errorf("aws metadata answered with status code %d", res.StatusCode)
 	}
 	if res.StatusCode/100 != 2 && res.StatusCode/100 != 4 {
 		log.Debug("Invalid status code while checking aws metadata: " + fmt.Sprint(res.StatusCode))
 		log.Debug("Invalid aws metadata response: " + string(res.Body))
-		return false
+		return false, nil
 	}
 
 	log.Debug("This is a aws machine")
-	return true
+	return true, nil
 }
 
-func isGcpMachine() bool {
+// isGcpMachine : whether the gcp metadata endpoint answers, an error when it could not be asked
+func isGcpMachine() (bool, error) {
 	req := request.Request{
 		Method:  "GET",
 		URL:     constants.GcpMetadataUrl,
-		Timeout: 2 * time.Second,
+		Timeout: constants.MetadataProbeTimeout,
 		Header: map[string]string{
 			"Metadata-Flavor": "Google",
 		},
@@ -233,15 +309,18 @@ func isGcpMachine() bool {
 	res := req.Make()
 	if res.Error != nil {
 		log.Debug("Error making http request to fetch gcp metadata: " + res.Error.Error())
-		return false
+		return false, res.Error
+	}
+	if res.StatusCode >= 500 {
+		return false, fmt.Errorf("gcp metadata answered with status code %d", res.StatusCode)
 	}
 	if res.StatusCode != 200 {
 		log.Debug("Invalid status code while checking gcp metadata: " + fmt.Sprint(res.StatusCode))
-		return false
+		return false, nil
 	}
 
 	log.Debug("This is a gcp machine")
-	return true
+	return true, nil
 }
 
 func DereferenceString(ptr *string) string {
@@ -251,6 +330,50 @@ func DereferenceString(ptr *string) string {
 	return ""
 }
 
-func IsCloudMachine() bool {
-	return isAwsMachine() || isGcpMachine()
+// IsCloudMachine : probe the aws and gcp metadata endpoints concurrently. The error is set when no endpoint answered
+// and one of them could not be asked, as a slow cloud machine then looks like any other.
+func IsCloudMachine() (bool, error) {
+	type probe struct {
+		found bool
+		err   error
+	}
+	gcp := make(chan probe, 1)
+	go func() {
+		found, err := isGcpMachine()
+		gcp <- probe{found: found, err: err}
+	}()
+	isAws, awsErr := isAwsMachine()
+	if isAws {
+		return true, nil
+	}
+	gcpProbe := <-gcp
+	if gcpProbe.found {
+		return true, nil
+	}
+	return false, errors.Join(awsErr, gcpProbe.err)
+}
+
+// ShellQuote : quote a value so that it is passed verbatim as a single argument to a POSIX shell
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// GetLivelogsDir : directory holding the livelogs files of the current user, created when missing
+func GetLivelogsDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	livelogsDir := filepath.Join(homeDir, constants.LivelogsDirName)
+	if err := os.MkdirAll(livelogsDir, 0700); err != nil {
+		return "", err
+	}
+	return livelogsDir, nil
+}
+
+// RegisterLogSearchConfigSecrets : keep the secrets of the config out of debug logs
+func RegisterLogSearchConfigSecrets(config models.LogSearchConfig) {
+	for _, secret := range config.Secrets() {
+		logger.RegisterSecret(secret)
+	}
 }
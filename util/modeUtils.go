@@ -0,0 +1,110 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dream11/livelogs/constants"
+)
+
+// detectedMode is the cached result of probing the machine
+type detectedMode struct {
+	Mode       string    `json:"mode"`
+	Hostname   string    `json:"hostname"`
+	DetectedAt time.Time `json:"detectedAt"`
+	// Uncertain is set when a probe failed, the local mode then found is probed again sooner
+	Uncertain bool `json:"uncertain,omitempty"`
+}
+
+// processMode is the mode detected by this process, kept even when it is not cached so that the probes run once
+var processMode struct {
+	once sync.Once
+	mode string
+}
+
+// ResolveMode : local or central, from the mode asked for, else LIVELOGS_MODE, else the cached detection
+func ResolveMode(mode string) (string, error) {
+	source := "--" + constants.ArgumentMode
+	if mode == "" {
+		mode, source = os.Getenv(constants.EnvLivelogsMode), constants.EnvLivelogsMode
+	}
+	switch strings.ToLower(mode) {
+	case constants.ModeLocal:
+		return constants.ModeLocal, nil
+	case constants.ModeCentral:
+		return constants.ModeCentral, nil
+	case "", constants.ModeAuto:
+		processMode.once.Do(func() {
+			processMode.mode = detectMode()
+		})
+		return processMode.mode, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q, must be one of: %s, %s, %s", source, mode, constants.ModeLocal, constants.ModeCentral, constants.ModeAuto)
+	}
+}
+
+func detectMode() string {
+	hostname, _ := os.Hostname()
+	cachePath := ""
+	if livelogsDir, err := GetLivelogsDir(); err == nil {
+		cachePath = filepath.Join(livelogsDir, constants.ModeCacheFileName)
+		if cached, ok := readDetectedMode(cachePath, hostname); ok {
+			log.Debug("Using cached run mode: " + cached)
+			return cached
+		}
+	}
+
+	mode := constants.ModeLocal
+	isCloud, err := IsCloudMachine()
+	if isCloud {
+		mode = constants.ModeCentral
+	}
+	// Metadata endpoints are out of reach on most laptops, so a failed probe is cached as well, for less time as it may
+	// also hide a slow cloud machine
+	if err != nil {
+		log.Debug("Metadata probe failed, caching the run mode for " + constants.ModeCacheUncertainTtl.String() + ": " + err.Error())
+	}
+	if cachePath != "" {
+		writeDetectedMode(cachePath, detectedMode{Mode: mode, Hostname: hostname, DetectedAt: time.Now(), Uncertain: err != nil})
+	}
+	return mode
+}
+
+func readDetectedMode(path, hostname string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	var cached detectedMode
+	if err := json.Unmarshal(data, &cached); err != nil {
+		log.Debug("Ignoring invalid run mode cache: " + err.Error())
+		return "", false
+	}
+	ttl := constants.ModeCacheTtl
+	if cached.Uncertain {
+		ttl = constants.ModeCacheUncertainTtl
+	}
+	if cached.Hostname != hostname || time.Since(cached.DetectedAt) > ttl {
+		return "", false
+	}
+	if cached.Mode != constants.ModeLocal && cached.Mode != constants.ModeCentral {
+		return "", false
+	}
+	return cached.Mode, true
+}
+
+func writeDetectedMode(path string, detected detectedMode) {
+	data, err := json.Marshal(detected)
+	if err != nil {
+		log.Debug("Failed to encode run mode cache: " + err.Error())
+		return
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		log.Debug("Failed to write run mode cache: " + err.Error())
+	}
+}
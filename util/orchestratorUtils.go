@@ -0,0 +1,152 @@
+package util
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dream11/livelogs/constants"
+	"github.com/dream11/livelogs/pkg/config"
+	"github.com/dream11/livelogs/pkg/logger"
+	"github.com/dream11/livelogs/pkg/request"
+)
+
+// Orchestrator is the livelogs orchestrator serving log search configs and user session logs
+type Orchestrator struct {
+	url                string
+	token              string
+	allowInsecureToken bool
+	// transport trusts the CA bundle of the orchestrator, nil for the default one
+	transport http.RoundTripper
+	// warn shows the warnings of the orchestrator requests to the user
+	warn              func(message string)
+	warnInsecureToken sync.Once
+}
+
+// LoadConfig : content of ~/.livelogs/config.yaml
+func LoadConfig() (*config.Config, error) {
+	livelogsDir, err := GetLivelogsDir()
+	if err != nil {
+		return nil, err
+	}
+	return config.Load(config.Path(livelogsDir))
+}
+
+// NewOrchestrator : orchestrator at the URL and CA bundle given, else the ones of the LIVELOGS_ORCHESTRATOR_URL and
+// LIVELOGS_CA_BUNDLE environment variables, else the ones of the config file, authenticating with the token of
+// LIVELOGS_TOKEN or else the one stored by `livelogs login`
+func NewOrchestrator(url, caBundle string, warn func(message string)) (*Orchestrator, error) {
+	livelogsConfig, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	defaultUrl := constants.CentralLiveLogAgentHost
+	useHttps := livelogsConfig.Orchestrator.Https
+	if https, err := strconv.ParseBool(os.Getenv(constants.EnvOrchestratorHttps)); err == nil {
+		useHttps = https
+	}
+	if useHttps {
+		defaultUrl = "https://" + strings.TrimPrefix(defaultUrl, "http://")
+	}
+	url = firstNonEmpty(url, os.Getenv(constants.EnvOrchestratorUrl), livelogsConfig.Orchestrator.URL, defaultUrl)
+	caBundle = firstNonEmpty(caBundle, os.Getenv(constants.EnvCABundle), livelogsConfig.Orchestrator.CABundle)
+
+	orchestrator := &Orchestrator{
+		url:                NormalizeOrchestratorUrl(url),
+		allowInsecureToken: livelogsConfig.Orchestrator.AllowInsecureToken,
+		warn:               warn,
+	}
+	if caBundle != "" {
+		if orchestrator.transport, err = request.NewTransport(expandHome(caBundle)); err != nil {
+			return nil, err
+		}
+	}
+
+	orchestrator.token = os.Getenv(constants.EnvLivelogsToken)
+	if orchestrator.token == "" {
+		if orchestrator.token, err = GetStoredToken(url); err != nil {
+			return nil, err
+		}
+	}
+	logger.RegisterSecret(orchestrator.token)
+
+	if allow, err := strconv.ParseBool(os.Getenv(constants.EnvAllowInsecureToken)); err == nil {
+		orchestrator.allowInsecureToken = allow
+	}
+	return orchestrator, nil
+}
+
+// Url : base URL of the orchestrator
+func (o *Orchestrator) Url() string {
+	return o.url
+}
+
+// NormalizeOrchestratorUrl : orchestrator URL as the key of its credentials
+func NormalizeOrchestratorUrl(url string) string {
+	return strings.TrimRight(url, "/")
+}
+
+// GetStoredToken : token stored by `livelogs login` for an orchestrator
+func GetStoredToken(url string) (string, error) {
+	livelogsDir, err := GetLivelogsDir()
+	if err != nil {
+		return "", err
+	}
+	credentials, err := config.LoadCredentials(config.CredentialsPath(livelogsDir))
+	if err != nil {
+		return "", err
+	}
+	return credentials.Tokens[NormalizeOrchestratorUrl(url)], nil
+}
+
+// StoreToken : save the token of an orchestrator, returning the path of the credentials file
+func StoreToken(url, token string) (string, error) {
+	livelogsDir, err := GetLivelogsDir()
+	if err != nil {
+		return "", err
+	}
+	path := config.CredentialsPath(livelogsDir)
+	credentials, err := config.LoadCredentials(path)
+	if err != nil {
+		return "", err
+	}
+	credentials.Tokens[NormalizeOrchestratorUrl(url)] = token
+	return path, credentials.Save(path)
+}
+
+// header : headers of an orchestrator request, with the bearer token when there is one. The token is only sent in
+// plain text when it is allowed explicitly, as anyone on the path could capture it.
+func (o *Orchestrator) header(header map[string]string) map[string]string {
+	if o.token == "" {
+		return header
+	}
+	if !strings.HasPrefix(strings.ToLower(o.url), "https://") && !o.allowInsecureToken {
+		o.warnInsecureToken.Do(func() {
+			o.warn("Not sending the token to " + o.url + " as it is not https, set " + constants.EnvAllowInsecureToken + "=true to send it anyway")
+		})
+		return header
+	}
+	header["Authorization"] = "Bearer " + o.token
+	return header
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func expandHome(path string) string {
+	if rest, found := strings.CutPrefix(path, "~/"); found {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, rest)
+		}
+	}
+	return path
+}
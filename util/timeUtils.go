@@ -0,0 +1,98 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Bundled so that --timezone works on machines without a system zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/dream11/livelogs/constants"
+)
+
+var absoluteTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+var clockLayouts = []string{"15:04:05", "15:04"}
+
+// LoadTimezone : resolve an IANA timezone name, empty defaults to IST for compatibility and "local" is the machine timezone
+func LoadTimezone(name string) (*time.Location, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		name = constants.DefaultTimezone
+	case "local":
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return location, nil
+}
+
+// ParseTimeExpression : resolve a time expression relative to now, supported expressions are
+// "2006-01-02 15:04:05", "2006-01-02", RFC3339, "now", "today 14:00", "yesterday 14:00", "-2h" and "2h ago"
+func ParseTimeExpression(expression string, location *time.Location, now time.Time) (time.Time, error) {
+	expression = strings.TrimSpace(expression)
+	lowerExpression := strings.ToLower(expression)
+
+	if lowerExpression == "now" {
+		return now, nil
+	}
+
+	if relative, found := strings.CutSuffix(lowerExpression, " ago"); found {
+		duration, err := time.ParseDuration(strings.TrimSpace(relative))
+		if err == nil && duration >= 0 {
+			return now.Add(-duration), nil
+		}
+	}
+	if strings.HasPrefix(lowerExpression, "-") {
+		if duration, err := time.ParseDuration(lowerExpression); err == nil {
+			return now.Add(duration), nil
+		}
+	}
+
+	for prefix, days := range map[string]int{"today": 0, "yesterday": -1} {
+		if clock, found := strings.CutPrefix(lowerExpression, prefix); found {
+			return parseClockOnDay(strings.TrimSpace(clock), now.In(location).AddDate(0, 0, days), expression)
+		}
+	}
+
+	if parsedTime, err := time.Parse(time.RFC3339, expression); err == nil {
+		return parsedTime, nil
+	}
+	for _, layout := range absoluteTimeLayouts {
+		if parsedTime, err := time.ParseInLocation(layout, expression, location); err == nil {
+			return parsedTime, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time %q, use \"2006-01-02 15:04:05\", \"2006-01-02\", RFC3339, \"today 14:00\" or a relative time like -2h", expression)
+}
+
+func parseClockOnDay(clock string, day time.Time, expression string) (time.Time, error) {
+	year, month, date := day.Date()
+	if clock == "" {
+		return time.Date(year, month, date, 0, 0, 0, 0, day.Location()), nil
+	}
+	for _, layout := range clockLayouts {
+		if parsedClock, err := time.Parse(layout, clock); err == nil {
+			return time.Date(year, month, date, parsedClock.Hour(), parsedClock.Minute(), parsedClock.Second(), 0, day.Location()), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time of day in %q", expression)
+}
+
+// ResolveTime : parse a time expression in the named timezone
+func ResolveTime(expression, timezone string) (time.Time, error) {
+	location, err := LoadTimezone(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ParseTimeExpression(expression, location, time.Now())
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoadTimezone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := LoadTimezone(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func TestLoadTimezone(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "", want: "Asia/Kolkata"},
+		{name: "  ", want: "Asia/Kolkata"},
+		{name: "UTC", want: "UTC"},
+		{name: "Europe/London", want: "Europe/London"},
+		{name: "local", want: time.Local.String()},
+		{name: "LOCAL", want: time.Local.String()},
+		{name: "Mars/Olympus", wantErr: true},
+		{name: "+05:30", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			location, err := LoadTimezone(test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, test.wantErr)
+			}
+			if err == nil && location.String() != test.want {
+				t.Errorf("location = %s, want %s", location, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeExpression(t *testing.T) {
+	// 20:30 UTC is already the next day in Kolkata and still the same day in New York
+	now := time.Date(2024, 3, 9, 20, 30, 0, 0, time.UTC)
+	kolkata := mustLoadTimezone(t, "Asia/Kolkata")
+	newYork := mustLoadTimezone(t, "America/New_York")
+
+	tests := []struct {
+		name       string
+		expression string
+		location   *time.Location
+		want       time.Time
+	}{
+		{"now", " NOW ", kolkata, now},
+		{"seconds ago", "30s ago", kolkata, now.Add(-30 * time.Second)},
+		{"minutes ago", "15m ago", kolkata, now.Add(-15 * time.Minute)},
+		{"hours ago", "2h ago", kolkata, now.Add(-2 * time.Hour)},
+		{"compound ago", "1h30m ago", kolkata, now.Add(-90 * time.Minute)},
+		{"upper case ago", "2H AGO", kolkata, now.Add(-2 * time.Hour)},
+		{"negative minutes", "-45m", kolkata, now.Add(-45 * time.Minute)},
+		{"negative compound", "-1h15m30s", kolkata, now.Add(-(75*time.Minute + 30*time.Second))},
+		{"date time in Kolkata", "2024-03-09 10:00:00", kolkata, time.Date(2024, 3, 9, 4, 30, 0, 0, time.UTC)},
+		{"date time in New York", "2024-03-09 10:00:00", newYork, time.Date(2024, 3, 9, 15, 0, 0, 0, time.UTC)},
+		{"date time in UTC", "2024-03-09 10:00", time.UTC, time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)},
+		{"T separator", "2024-03-09T10:00:05", time.UTC, time.Date(2024, 3, 9, 10, 0, 5, 0, time.UTC)},
+		{"date only", "2024-03-09", kolkata, time.Date(2024, 3, 8, 18, 30, 0, 0, time.UTC)},
+		{"daylight saving time", "2024-03-10 12:00:00", newYork, time.Date(2024, 3, 10, 16, 0, 0, 0, time.UTC)},
+		{"RFC3339 keeps its offset", "2024-03-09T10:00:00+02:00", kolkata, time.Date(2024, 3, 9, 8, 0, 0, 0, time.UTC)},
+		{"today in Kolkata", "today 14:00", kolkata, time.Date(2024, 3, 10, 8, 30, 0, 0, time.UTC)},
+		{"today in New York", "today 14:00", newYork, time.Date(2024, 3, 9, 19, 0, 0, 0, time.UTC)},
+		{"today with seconds", "Today 14:00:30", time.UTC, time.Date(2024, 3, 9, 14, 0, 30, 0, time.UTC)},
+		{"today at midnight", "today", kolkata, time.Date(2024, 3, 9, 18, 30, 0, 0, time.UTC)},
+		{"yesterday in Kolkata", "yesterday 23:00", kolkata, time.Date(2024, 3, 9, 17, 30, 0, 0, time.UTC)},
+		{"yesterday in UTC", "yesterday 23:00", time.UTC, time.Date(2024, 3, 8, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseTimeExpression(test.expression, test.location, now)
+			if err != nil {
+				t.Fatalf("ParseTimeExpression(%q): %v", test.expression, err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("ParseTimeExpression(%q) = %v, want %v", test.expression, got.UTC(), test.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeExpressionErrors(t *testing.T) {
+	now := time.Date(2024, 3, 9, 20, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "unable to parse time"},
+		{"2d ago", "unable to parse time"},
+		{"-2d", "unable to parse time"},
+		{"-2h ago", "unable to parse time"},
+		{"2h", "unable to parse time"},
+		{"09/03/2024", "unable to parse time"},
+		{"2024-13-01", "unable to parse time"},
+		{"today 25:00", "unable to parse time of day"},
+		{"yesterday noon", "unable to parse time of day"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := ParseTimeExpression(test.expression, time.UTC, now)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("ParseTimeExpression(%q) = %v, want an error containing %q", test.expression, err, test.want)
+			}
+		})
+	}
+}
+
+// A start given after the end must still resolve to a later time, for the window validation to reject it
+func TestParseTimeExpressionOrder(t *testing.T) {
+	now := time.Date(2024, 3, 9, 20, 30, 0, 0, time.UTC)
+	kolkata := mustLoadTimezone(t, "Asia/Kolkata")
+	tests := []struct {
+		start, end string
+		wantBefore bool
+	}{
+		{"2h ago", "1h ago", true},
+		{"-1h", "2h ago", false},
+		{"-30m", "now", true},
+		{"now", "-1s", false},
+		{"yesterday 23:00", "today 01:00", true},
+		{"today 14:00", "today 13:59", false},
+		{"2024-03-09 10:00:00", "2024-03-09T04:30:00Z", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.start+" to "+test.end, func(t *testing.T) {
+			start, err := ParseTimeExpression(test.start, kolkata, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			end, err := ParseTimeExpression(test.end, kolkata, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := start.Before(end); got != test.wantBefore {
+				t.Errorf("%s before %s = %v, want %v", start.UTC(), end.UTC(), got, test.wantBefore)
+			}
+		})
+	}
+}